@@ -1,14 +1,10 @@
 package main
 
 import (
-	"bufio"
 	"encoding/xml"
 	"errors"
 	"fmt"
-	"os"
 	"sort"
-	"strconv"
-	"strings"
 )
 
 type TracePart struct {
@@ -21,15 +17,40 @@ const (
 	SKIP string = "»"
 )
 
-var (
-	Trmap map[string]Transition
-	Trace []TracePart
-)
+// AlignContext holds the state of the reconstruction of the alignment of one
+// synchronous product, so alignments of several products can be
+// reconstructed concurrently
+type AlignContext struct {
+	Product   PNML
+	Trace     []TracePart // the last reconstructed trace
+	Alignment AlignmentS  // the alignment of Trace
+	trmap     map[string]Transition
+}
+
+// returns a context for the synchronous product written by -p
+func NewAlignContext(syncmodelfn string) (*AlignContext, error) {
+	ctx := &AlignContext{}
+	if err := xml.Unmarshal(readPNML(syncmodelfn), &ctx.Product); err != nil {
+		return nil, fmt.Errorf("%s: %v", syncmodelfn, err)
+	}
+	return ctx, nil
+}
+
+// reconstructs the alignment of the trace, replacing the previous trace
+func (ctx *AlignContext) Reconstruct(trace []TracePart) (AlignmentS, error) {
+	al, err := ctx.Product.ReconstructAlignment(trace)
+	if err != nil {
+		return al, err
+	}
+	ctx.Trace = trace
+	ctx.Alignment = al
+	return al, nil
+}
 
 // - "SYNC-[in,in,in]-[out,out,out]" -> transition
 // NB: we might return a list of transitions, but a single one is enough
-func (pn *PNML) CreateTransitionMap() {
-	Trmap = make(map[string]Transition)
+func (pn *PNML) CreateTransitionMap() map[string]Transition {
+	trmap := make(map[string]Transition)
 	for _, trans := range pn.Net.Page.Transitions {
 		var in, out []string
 		// find the corresponding arcs
@@ -43,69 +64,59 @@ func (pn *PNML) CreateTransitionMap() {
 		}
 		sort.Strings(in)
 		sort.Strings(out)
-		Trmap[fmt.Sprintf("%s-%s-%s", trans.Type, in, out)] = trans
+		trmap[fmt.Sprintf("%s-%s-%s", trans.Type, in, out)] = trans
 	}
+	return trmap
 }
 
-func AddAlignPair(t Transition) {
-	pair := AlignPair{Log: SKIP, Trans: SKIP, TransID: ""}
+// adds the move of transition t of net pn, the model side of a sync move is
+// the label of the model transition it is mapped to
+func (al *AlignmentS) AddPair(pn *PNML, t Transition) {
+	pair := AlignPair{Log: SKIP, Trans: SKIP, TransID: "", Type: t.Type}
 	if t.Type == LOG || t.Type == SYNC {
 		pair.Log = t.OrigName
 		pair.TransID = t.ID
 	}
-	if t.Type == MODEL || t.Type == SYNC || t.Type == TAU {
+	if t.Type == MODEL || t.Type == TAU {
 		pair.Trans = t.OrigName
 		pair.TransID = t.ID
 	}
-	Alignment.Pairs = append(Alignment.Pairs, pair)
+	if t.Type == SYNC {
+		pair.Trans = pn.syncModelLabel(t)
+	}
+	al.Pairs = append(al.Pairs, pair)
 }
 
-func TraceToAlignOld(syncmodelfn, tracefn string) {
-	// read trace
-	file, err := os.Open(tracefn)
-	CheckError(err)
-	defer file.Close()
+// returns the label of the model transition that sync transition t is mapped
+// to, or its log label for products without the mapping
+func (pn *PNML) syncModelLabel(t Transition) string {
+	for _, trans := range pn.Net.Page.Transitions {
+		if t.Model != "" && trans.ID == t.Model {
+			return trans.OrigName
+		}
+	}
+	return t.OrigName
+}
 
+func TraceToAlignOld(syncmodelfn, tracefn string) {
 	// read PNML
-	modelcontents := readPNML(syncmodelfn)
-	var pn PNML
-	xml.Unmarshal(modelcontents, &pn) // fill in PNML contents
+	ctx, err := NewAlignContext(syncmodelfn)
+	CheckError(err)
+	pn := &ctx.Product
 
 	// initialize mapping from string to transitions (there might be some loss
 	// of information, but this shouldn't be a problem)
-	pn.CreateTransitionMap()
+	ctx.trmap = pn.CreateTransitionMap()
 
 	// put the information in TraceParts, and collect these in Trace
-	var currentTP TracePart = TracePart{MoveType: "INITIAL"}
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "action") {
-			Trace = append(Trace, currentTP)
-			currentTP = TracePart{}
-			for _, movetype := range MOVES {
-				if strings.Contains(line, movetype) {
-					currentTP.MoveType = movetype
-				}
-			}
-		} else if strings.Contains(line, "place") {
-			split := strings.Split(line, " ")
-			if len(split) < 3 {
-				CheckError(errors.New("Unable to parse line: '" + line + "'"))
-			}
-			placeID := strings.Split(split[len(split)-3], ":")[0]
-			tokencount, err := strconv.Atoi(split[len(split)-1])
-			CheckError(err)
-			currentTP.PlaceIDs = append(currentTP.PlaceIDs, placeID)
-			currentTP.PlaceTokens = append(currentTP.PlaceTokens, tokencount)
-		}
-	}
-	Trace = append(Trace, currentTP)
+	trace, err := ReadTrace(tracefn)
+	CheckError(err)
+	ctx.Trace = append(ctx.Trace, trace...)
 
 	// Form an alignment from the Trace object
 	var marking = make(map[string]int)
-	for _, tp := range Trace {
-		if tp.MoveType == "INITIAL" {
+	for _, tp := range ctx.Trace {
+		if tp.MoveType == INITIAL {
 			for i, placeID := range tp.PlaceIDs {
 				marking[placeID] = tp.PlaceTokens[i]
 			}
@@ -129,14 +140,14 @@ func TraceToAlignOld(syncmodelfn, tracefn string) {
 
 		sort.Strings(inP)
 		sort.Strings(outP)
-		trans := Trmap[fmt.Sprintf("%s-%s-%s", tp.MoveType, inP, outP)]
+		trans := ctx.trmap[fmt.Sprintf("%s-%s-%s", tp.MoveType, inP, outP)]
 		fmt.Printf("%s in: %s out: %s, %v\n\n", tp.PlaceIDs, inP, outP, trans)
 		if trans.Type == "" {
 			CheckError(errors.New(fmt.Sprintf("Unable to form transition from"+
 				" marking difference: %s-%s-%s. Are you sure the input files"+
 				" are up to date?", tp.MoveType, inP, outP)))
 		}
-		AddAlignPair(trans)
+		ctx.Alignment.AddPair(pn, trans)
 		// change type in actual transition
 		for ti, tr := range pn.Net.Page.Transitions {
 			if tr.ID == trans.ID {
@@ -145,7 +156,7 @@ func TraceToAlignOld(syncmodelfn, tracefn string) {
 		}
 	}
 
-	fmt.Println(Alignment.toString())
+	fmt.Println(ctx.Alignment.toString())
 	//pn.PrintDOT(syncmodelfn[0:len(syncmodelfn)-5] + ".dot")
 }
 
@@ -197,47 +208,36 @@ func (pn *PNML) MakeTransArr(placeMap map[string]int) TransArr {
 	return ret
 }
 
-func TraceToAlign(syncmodelfn, tracefn string) {
-	// read trace
-	file, err := os.Open(tracefn)
+func TraceToAlign(syncmodelfn, tracefn, format string) {
+	// read PNML
+	ctx, err := NewAlignContext(syncmodelfn)
 	CheckError(err)
-	defer file.Close()
 
-	// read PNML
-	modelcontents := readPNML(syncmodelfn)
-	var pn PNML
-	xml.Unmarshal(modelcontents, &pn) // fill in PNML contents
+	// put the information in TraceParts, and reconstruct the alignment
+	trace, err := ReadTrace(tracefn)
+	CheckError(err)
+	_, err = ctx.Reconstruct(trace)
+	CheckError(err)
 
-	// put the information in TraceParts, and collect these in Trace
-	var currentTP TracePart = TracePart{MoveType: "INITIAL"}
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "action") {
-			Trace = append(Trace, currentTP)
-			currentTP = TracePart{}
-			for _, movetype := range MOVES {
-				if strings.Contains(line, movetype) {
-					currentTP.MoveType = movetype
-				}
-			}
-		} else if strings.Contains(line, "place") {
-			split := strings.Split(line, " ")
-			if len(split) < 3 {
-				CheckError(errors.New("Unable to parse line: '" + line + "'"))
-			}
-			placeID := strings.Split(split[len(split)-3], ":")[0]
-			tokencount, err := strconv.Atoi(split[len(split)-1])
-			CheckError(err)
-			currentTP.PlaceIDs = append(currentTP.PlaceIDs, placeID)
-			currentTP.PlaceTokens = append(currentTP.PlaceTokens, tokencount)
-		}
+	if format == "json" {
+		fmt.Println(ctx.Alignment.toJSON())
+	} else {
+		fmt.Println(ctx.Alignment.toString())
+	}
+}
+
+// Forms an alignment from the trace of the synchronous product, by
+// searching for each step the transition that changes the marking as given
+// by the trace
+func (pn *PNML) ReconstructAlignment(trace []TracePart) (AlignmentS, error) {
+	var al AlignmentS
+	if len(trace) == 0 {
+		return al, errors.New("Empty trace")
 	}
-	Trace = append(Trace, currentTP)
 
 	// create mapping for placeArr
 	placeMap := make(map[string]int)
-	for i, p := range Trace[0].PlaceIDs {
+	for i, p := range trace[0].PlaceIDs {
 		placeMap[p] = i
 	}
 
@@ -245,18 +245,18 @@ func TraceToAlign(syncmodelfn, tracefn string) {
 	Tarr := pn.MakeTransArr(placeMap)
 
 	// set initial marking
-	L := len(Trace[0].PlaceTokens)
+	L := len(trace[0].PlaceTokens)
 	currentMarking := make([]int, L)
 	newMarking := make([]int, L)
 	tmpMarking := make([]int, L)
-	for i, _ := range Trace[0].PlaceTokens {
-		currentMarking[i] = Trace[0].PlaceTokens[i]
-		newMarking[i] = Trace[0].PlaceTokens[i]
+	for i, _ := range trace[0].PlaceTokens {
+		currentMarking[i] = trace[0].PlaceTokens[i]
+		newMarking[i] = trace[0].PlaceTokens[i]
 	}
 
 	// search for matching transition
-	for _, tp := range Trace {
-		if tp.MoveType == "INITIAL" {
+	for _, tp := range trace {
+		if tp.MoveType == INITIAL {
 			continue
 		}
 		// set new marking
@@ -309,14 +309,13 @@ func TraceToAlign(syncmodelfn, tracefn string) {
 				}
 			}
 			if canfire { // correct transition chosen!
-				AddAlignPair(trans.T)
+				al.AddPair(pn, trans.T)
 				foundTrans = true
 				break
 			}
 		}
 		if !foundTrans {
-			CheckError(errors.New(
-				fmt.Sprintf("Could not find fitting transition: %v", tp)))
+			return al, fmt.Errorf("Could not find fitting transition: %v", tp)
 		}
 
 		for i, n := range newMarking {
@@ -324,5 +323,5 @@ func TraceToAlign(syncmodelfn, tracefn string) {
 		}
 	}
 
-	fmt.Println(Alignment.toString())
+	return al, nil
 }
@@ -1,19 +1,21 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
 )
 
-var Alignment AlignmentS
-
 type AlignmentS struct {
-	Pairs []AlignPair
+	Pairs []AlignPair `json:"pairs"`
 }
 
 type AlignPair struct {
-	Log     string
-	Trans   string
-	TransID string
+	Log     string `json:"log"`
+	Trans   string `json:"trans"`
+	TransID string `json:"transid"`
+	Type    string `json:"type"` // move type: LOG, MODEL, SYNC or TAU
 }
 
 func (al *AlignmentS) toString() string {
@@ -24,6 +26,76 @@ func (al *AlignmentS) toString() string {
 	return ret
 }
 
+// the number of log and model moves
+func (al *AlignmentS) Cost() int {
+	ret := 0
+	for _, pair := range al.Pairs {
+		if pair.Type == LOG || pair.Type == MODEL {
+			ret += 1
+		}
+	}
+	return ret
+}
+
+func (al *AlignmentS) toJSON() string {
+	output, err := json.MarshalIndent(al, "", "  ")
+	CheckError(err)
+	return string(output)
+}
+
 func (ap *AlignPair) toString() string {
 	return fmt.Sprintf("(%s | %s : %s)", ap.Log, ap.Trans, ap.TransID)
 }
+
+// Reads an alignment as printed by toString or toJSON, the move types of the
+// text format are derived from the skips
+func ReadAlignment(filename string) (AlignmentS, error) {
+	var al AlignmentS
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return al, err
+	}
+	if strings.HasSuffix(filename, ".json") {
+		if err := json.Unmarshal(contents, &al); err != nil {
+			return al, fmt.Errorf("%s: %v", filename, err)
+		}
+		for i, _ := range al.Pairs {
+			if al.Pairs[i].Type == "" {
+				al.Pairs[i].Type = al.Pairs[i].moveType()
+			}
+		}
+		return al, nil
+	}
+	for i, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		sep := strings.Index(line, " | ")
+		idSep := strings.LastIndex(line, " : ")
+		if !strings.HasPrefix(line, "(") || !strings.HasSuffix(line, ")") ||
+			sep == -1 || idSep < sep {
+			return al, fmt.Errorf("%s:%d: expected '(log | model : id)': '%s'",
+				filename, i+1, line)
+		}
+		pair := AlignPair{Log: line[1:sep], Trans: line[sep+3 : idSep],
+			TransID: line[idSep+3 : len(line)-1]}
+		pair.Type = pair.moveType()
+		al.Pairs = append(al.Pairs, pair)
+	}
+	return al, nil
+}
+
+// derives the move type from the skips
+func (ap *AlignPair) moveType() string {
+	switch {
+	case ap.Log != SKIP && ap.Trans != SKIP:
+		return SYNC
+	case ap.Log != SKIP:
+		return LOG
+	case ap.Trans == TAUSYM:
+		return TAU
+	default:
+		return MODEL
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Annotation of the original model with the moves of the optimal alignments
+// of the whole log: transitions show their sync and model move counts, and
+// log moves are dangling "inserted activity" nodes next to the places marked
+// when they occurred. The colour intensity grows with the frequency.
+
+type insertedActivity struct {
+	Label  string
+	Places []string // model places marked when the log move occurred
+	Count  int
+}
+
+type ModelAnnotation struct {
+	Sync     map[string]int // model transition ID -> sync moves
+	Model    map[string]int // model transition ID -> model moves
+	Inserted []insertedActivity
+}
+
+// aggregates the moves of the alignments on the (post-processed) model
+func (pn *PNML) Annotate(tas []TraceAlignment) ModelAnnotation {
+	ann := ModelAnnotation{Sync: make(map[string]int),
+		Model: make(map[string]int)}
+	inserted := make(map[string]int) // key -> index in Inserted
+	for _, ta := range tas {
+		if ta.Err != nil {
+			continue
+		}
+		ta.Replay(func(pair AlignPair, m MGMarking) {
+			switch pair.Type {
+			case SYNC:
+				ann.Sync[ta.modelTransID(pair)] += 1
+			case MODEL:
+				ann.Model[pair.TransID] += 1
+			case LOG:
+				var places []string
+				for _, place := range pn.projectMarking(m).Places {
+					places = append(places, place.ID)
+				}
+				sort.Strings(places)
+				key := pair.Log + "@" + strings.Join(places, ",")
+				i, ok := inserted[key]
+				if !ok {
+					i = len(ann.Inserted)
+					inserted[key] = i
+					ann.Inserted = append(ann.Inserted,
+						insertedActivity{Label: pair.Log, Places: places})
+				}
+				ann.Inserted[i].Count += 1
+			}
+		})
+	}
+	return ann
+}
+
+// returns a graphviz HSV colour of the hue, with saturation growing with n
+func dotIntensityColor(hue float64, n, max int) string {
+	sat := 0.0
+	if max > 0 && n > 0 {
+		sat = 0.15 + 0.85*float64(n)/float64(max)
+	}
+	return fmt.Sprintf("%.3f %.3f 1.000", hue, sat)
+}
+
+// Returns the annotated model as a graph. Transitions without model moves
+// are green (as sync moves), with model moves they shift to blue (as model
+// moves); inserted activities are orange (as log moves).
+func (pn *PNML) annotatedGraph(ann ModelAnnotation) *layoutGraph {
+	const (
+		syncHue  = 0.25
+		modelHue = 0.57
+		logHue   = 0.11
+	)
+	maxTrans, maxLog := 0, 0
+	for _, trans := range pn.Net.Page.Transitions {
+		if n := ann.Sync[trans.ID] + ann.Model[trans.ID]; n > maxTrans {
+			maxTrans = n
+		}
+	}
+	for _, ins := range ann.Inserted {
+		if ins.Count > maxLog {
+			maxLog = ins.Count
+		}
+	}
+
+	g := newLayoutGraph()
+	for _, place := range pn.Net.Page.Places {
+		g.addNode(layoutNode{ID: place.ID, Lines: []string{place.ID},
+			Shape: SHAPECIRCLE, Fill: dotTypeColor(place.Type, "")})
+	}
+	for _, trans := range pn.Net.Page.Transitions {
+		name := trans.Name
+		if trans.Type == TAU {
+			name = TAUSYM
+		}
+		sync, model := ann.Sync[trans.ID], ann.Model[trans.ID]
+		hue := syncHue
+		if sync+model > 0 {
+			hue += (modelHue - syncHue) * float64(model) /
+				float64(sync+model)
+		}
+		g.addNode(layoutNode{ID: trans.ID, Lines: []string{name,
+			fmt.Sprintf("sync: %d", sync), fmt.Sprintf("model: %d", model)},
+			Shape: SHAPEBOX,
+			Fill:  dotIntensityColor(hue, sync+model, maxTrans)})
+	}
+	for _, arc := range pn.Net.Page.Arcs {
+		g.addEdge(arc.Source, arc.Target, layoutEdge{
+			Color: pn.dotArcColor(&arc), Arrow: true})
+	}
+	for i, ins := range ann.Inserted {
+		id := fmt.Sprintf("inserted%d", i)
+		g.addNode(layoutNode{ID: id, Lines: []string{"+ " + ins.Label,
+			fmt.Sprintf("log: %d", ins.Count)}, Shape: SHAPEBOX,
+			Fill: dotIntensityColor(logHue, ins.Count, maxLog), Dashed: true})
+		for _, place := range ins.Places {
+			g.addEdge(place, id, layoutEdge{Color: "darkorange",
+				Dashed: true})
+		}
+	}
+	return g
+}
+
+// writes the model annotated with the alignments of the log, as SVG if the
+// file name ends with .svg and DOT otherwise
+func AnnotateModel(modelfn, logfn, outfn string, opts ProductOptions) {
+	CheckError(opts.Silent.Check())
+	model, _ := readModel(modelfn, opts.Silent)
+	logtraces := readLog(logfn)
+	labels := NewLabelMap(&model, opts.LabelFile, opts.Normalize)
+	tas := model.AlignTraces(logtraces, labels, opts)
+	for _, ta := range tas {
+		if ta.Err != nil {
+			fmt.Printf("trace %d not aligned: %v\n", ta.Trace, ta.Err)
+		}
+	}
+	g := model.annotatedGraph(model.Annotate(tas))
+	if strings.HasSuffix(outfn, ".svg") {
+		WriteFile(outfn, g.SVG())
+	} else {
+		WriteFile(outfn, g.DOT())
+	}
+}
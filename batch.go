@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Batch reconstruction of the alignments for all synchronous products in an
+// output directory of -p. The solver trace of product i is found with a
+// pattern, by default "trace-%d" with any of the supported trace extensions.
+// The products are found with their name pattern of -p, by default
+// "syncmodel-%d".
+
+var TRACEEXTS = []string{".txt", ".csv", ".gcf", ".dir", ".json"}
+
+const DEFAULTTRACEPATTERN string = "trace-%d"
+
+type BatchResult struct {
+	Trace     int         `json:"trace"`
+	Cost      int         `json:"cost"`
+	Alignment *AlignmentS `json:"alignment,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Missing   bool        `json:"missing,omitempty"`
+}
+
+// returns the indices of the synchronous products in the directory with the
+// name pattern
+func syncModelIndices(dir, name string) ([]int, error) {
+	if err := CheckNamePattern(name); err != nil {
+		return nil, err
+	}
+	parts := strings.SplitN(name, "%d", 2)
+	re := regexp.MustCompile("^" + regexp.QuoteMeta(parts[0]) + `(\d+)` +
+		regexp.QuoteMeta(parts[1]) + `\.pnml$`)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ret []int
+	for _, file := range files {
+		match := re.FindStringSubmatch(file.Name())
+		if match != nil {
+			i, _ := strconv.Atoi(match[1])
+			ret = append(ret, i)
+		}
+	}
+	sort.Ints(ret)
+	return ret, nil
+}
+
+// returns the trace file of product i, or "" if it doesn't exist
+func findTraceFile(dir, pattern string, i int) string {
+	name := filepath.Join(dir, fmt.Sprintf(pattern, i))
+	if filepath.Ext(name) != "" {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+		return ""
+	}
+	for _, ext := range TRACEEXTS {
+		if _, err := os.Stat(name + ext); err == nil {
+			return name + ext
+		}
+	}
+	return ""
+}
+
+func alignProduct(syncmodelfn, tracefn string) (AlignmentS, error) {
+	ctx, err := NewAlignContext(syncmodelfn)
+	if err != nil {
+		return AlignmentS{}, err
+	}
+	trace, err := ReadTrace(tracefn)
+	if err != nil {
+		return AlignmentS{}, err
+	}
+	return ctx.Reconstruct(trace)
+}
+
+func BatchAlign(dir, name, pattern string) []BatchResult {
+	CheckError(CheckNamePattern(pattern))
+	indices, err := syncModelIndices(dir, name)
+	CheckError(err)
+	var ret []BatchResult
+	for _, i := range indices {
+		res := BatchResult{Trace: i}
+		tracefn := findTraceFile(dir, pattern, i)
+		if tracefn == "" {
+			res.Missing = true
+			ret = append(ret, res)
+			continue
+		}
+		al, err := alignProduct(
+			filepath.Join(dir, fmt.Sprintf(name, i)+".pnml"), tracefn)
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Alignment = &al
+			res.Cost = al.Cost()
+		}
+		ret = append(ret, res)
+	}
+	return ret
+}
+
+// returns the alignments of BatchAlign with the products they are
+// reconstructed on, for the quality metrics
+func SolverAlignments(dir, name, pattern string) []TraceAlignment {
+	var ret []TraceAlignment
+	for _, res := range BatchAlign(dir, name, pattern) {
+		ta := TraceAlignment{Trace: res.Trace}
+		switch {
+		case res.Missing:
+			ta.Err = errors.New("Missing solver trace")
+		case res.Error != "":
+			ta.Err = errors.New(res.Error)
+		default:
+			ta.Alignment = *res.Alignment
+			ta.Cost = res.Cost
+			ta.Err = xml.Unmarshal(readPNML(filepath.Join(dir,
+				fmt.Sprintf(name, res.Trace)+".pnml")), &ta.Product)
+		}
+		ret = append(ret, ta)
+	}
+	return ret
+}
+
+func batchSummary(results []BatchResult) string {
+	var missing, failed []string
+	aligned := 0
+	for _, res := range results {
+		if res.Missing {
+			missing = append(missing, fmt.Sprint(res.Trace))
+		} else if res.Error != "" {
+			failed = append(failed, fmt.Sprintf("  %d: %s", res.Trace,
+				res.Error))
+		} else {
+			aligned += 1
+		}
+	}
+	ret := fmt.Sprintf("%d products, %d aligned, %d missing traces, %d"+
+		" failed\n", len(results), aligned, len(missing), len(failed))
+	if len(missing) > 0 {
+		ret += "Missing traces: " + strings.Join(missing, ", ") + "\n"
+	}
+	if len(failed) > 0 {
+		ret += "Failed traces:\n" + strings.Join(failed, "\n") + "\n"
+	}
+	return ret
+}
+
+// writes all alignments to 'alignments.{txt,json}' and a summary to
+// 'summary.txt' in the directory, the summary is also printed
+func BatchTraceToAlign(dir, name, pattern, format string) {
+	results := BatchAlign(dir, name, pattern)
+	if format == "json" {
+		output, err := json.MarshalIndent(results, "", "  ")
+		CheckError(err)
+		WriteFile(filepath.Join(dir, "alignments.json"), string(output))
+	} else {
+		ret := ""
+		for _, res := range results {
+			if res.Alignment != nil {
+				ret += fmt.Sprintf("trace %d (cost %d)\n%s\n", res.Trace,
+					res.Cost, res.Alignment.toString())
+			}
+		}
+		WriteFile(filepath.Join(dir, "alignments.txt"), ret)
+	}
+	summary := batchSummary(results)
+	WriteFile(filepath.Join(dir, "summary.txt"), summary)
+	fmt.Print(summary)
+}
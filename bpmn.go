@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Importing BPMN 2.0 models. Every sequence flow becomes a place, tasks
+// become labelled transitions and gateways and events become TAU
+// transitions. Tasks with multiple incoming flows are an implicit exclusive
+// join and tasks with multiple outgoing flows an implicit parallel split.
+// All end events flow into a single sink place, whose final marking is the
+// number of end events that complete concurrently.
+
+// limit on the number of markings explored for the final marking
+const BPMNMARKINGS int = 10000
+
+type BPMN struct {
+	XMLName   xml.Name      `xml:"definitions"`
+	Processes []BPMNProcess `xml:"process"`
+}
+
+type BPMNProcess struct {
+	ID    string     `xml:"id,attr"`
+	Name  string     `xml:"name,attr"`
+	Nodes []BPMNNode `xml:",any"`
+}
+
+// any element of a process, sequence flows are also parsed as nodes
+type BPMNNode struct {
+	XMLName   xml.Name
+	ID        string `xml:"id,attr"`
+	Name      string `xml:"name,attr"`
+	SourceRef string `xml:"sourceRef,attr"`
+	TargetRef string `xml:"targetRef,attr"`
+}
+
+func bpmnIsTask(kind string) bool {
+	switch kind {
+	case "task", "userTask", "serviceTask", "manualTask", "scriptTask",
+		"sendTask", "receiveTask", "businessRuleTask", "callActivity",
+		"subProcess":
+		return true
+	}
+	return false
+}
+
+// reads the BPMN file, which should contain exactly one process
+func parseBPMN(filename string) BPMN {
+	var bpmn BPMN
+	CheckError(xml.Unmarshal(readPNML(filename), &bpmn))
+	if len(bpmn.Processes) != 1 {
+		CheckError(errors.New("Expected exactly one process in BPMN file '" +
+			filename + "'"))
+	}
+	return bpmn
+}
+
+func ImportBPMN(filename string) PNML {
+	proc := parseBPMN(filename).Processes[0]
+	nb := newNetBuilder(proc.Name)
+
+	// flows to places
+	flowPlace := make(map[string]string)
+	in := make(map[string][]string)  // node ID -> incoming flow places
+	out := make(map[string][]string) // node ID -> outgoing flow places
+	for _, node := range proc.Nodes {
+		if node.XMLName.Local == "sequenceFlow" {
+			flowPlace[node.ID] = nb.addPlace(node.ID)
+			in[node.TargetRef] = append(in[node.TargetRef], flowPlace[node.ID])
+			out[node.SourceRef] = append(out[node.SourceRef],
+				flowPlace[node.ID])
+		}
+	}
+
+	source := nb.addPlace("source")
+	sink := nb.addPlace("sink")
+	for _, node := range proc.Nodes {
+		kind := node.XMLName.Local
+		switch {
+		case kind == "sequenceFlow":
+			continue
+		case kind == "startEvent":
+			nb.addTransition("", []string{source}, out[node.ID])
+		case kind == "endEvent":
+			for _, p := range in[node.ID] {
+				nb.addTransition("", []string{p}, []string{sink})
+			}
+		case kind == "parallelGateway":
+			checkBPMNIncoming(node, in)
+			nb.addTransition("", in[node.ID], out[node.ID])
+		case kind == "exclusiveGateway" || kind == "eventBasedGateway":
+			for _, p := range in[node.ID] {
+				for _, q := range out[node.ID] {
+					nb.addTransition("", []string{p}, []string{q})
+				}
+			}
+		case bpmnIsTask(kind) || kind == "intermediateCatchEvent" ||
+			kind == "intermediateThrowEvent":
+			label := node.Name
+			if !bpmnIsTask(kind) {
+				label = "" // events are silent
+			} else if label == "" {
+				label = node.ID
+			}
+			checkBPMNIncoming(node, in)
+			enter := in[node.ID]
+			if len(enter) > 1 {
+				// implicit exclusive join
+				p := nb.addPlace(node.ID + " join")
+				for _, q := range enter {
+					nb.addTransition("", []string{q}, []string{p})
+				}
+				enter = []string{p}
+			}
+			nb.addTransition(label, enter, out[node.ID])
+		case kind == "inclusiveGateway" || kind == "complexGateway":
+			CheckError(errors.New("Unsupported BPMN gateway '" + kind +
+				"' (" + node.ID + ")"))
+		}
+	}
+	nb.setMarking(source, sink)
+	tokens, err := nb.pn.completionTokens(sink)
+	CheckError(err)
+	for i, mp := range nb.pn.Net.FinalMarking.MPlaces {
+		if mp.ID == sink {
+			nb.pn.Net.FinalMarking.MPlaces[i].TokenCount = strconv.Itoa(tokens)
+		}
+	}
+	return nb.pn
+}
+
+// nodes without incoming flows would be transitions that are always enabled
+func checkBPMNIncoming(node BPMNNode, in map[string][]string) {
+	if len(in[node.ID]) == 0 {
+		CheckError(errors.New("BPMN node '" + node.ID + "' has no incoming" +
+			" sequence flow"))
+	}
+}
+
+// returns the number of tokens in the sink when the process completes, i.e.
+// in the dead markings that only mark the sink. Parallel branches that end in
+// different end events each put a token in the sink. The exploration is
+// bounded by BPMNMARKINGS.
+func (pn *PNML) completionTokens(sink string) (int, error) {
+	placeMap := make(map[string]int)
+	initial := make([]int, len(pn.Net.Page.Places))
+	for i, place := range pn.Net.Page.Places {
+		placeMap[place.ID] = i
+		initial[i], _ = strconv.Atoi(place.InitialMarking)
+	}
+	tarr := pn.MakeTransArr(placeMap)
+	seen := map[string]bool{markingKey(initial): true}
+	queue := [][]int{initial}
+	tokens := 0
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		dead := true
+		for _, trans := range tarr.Trans {
+			next := fireVector(trans, m)
+			if next == nil {
+				continue
+			}
+			dead = false
+			if key := markingKey(next); !seen[key] {
+				if len(seen) >= BPMNMARKINGS {
+					return 0, fmt.Errorf("Exceeded %d markings while"+
+						" computing the final marking, the process may be"+
+						" unbounded (a loop around a parallel split)",
+						BPMNMARKINGS)
+				}
+				seen[key] = true
+				queue = append(queue, next)
+			}
+		}
+		if !dead || m[placeMap[sink]] == 0 {
+			continue
+		}
+		completed := true
+		for p, n := range m {
+			if n > 0 && p != placeMap[sink] {
+				completed = false // deadlock
+			}
+		}
+		if !completed {
+			continue
+		}
+		if tokens != 0 && tokens != m[placeMap[sink]] {
+			return 0, fmt.Errorf("The process completes with %d and %d"+
+				" tokens in the sink", tokens, m[placeMap[sink]])
+		}
+		tokens = m[placeMap[sink]]
+	}
+	if tokens == 0 {
+		return 1, nil // the process never completes
+	}
+	return tokens, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportBPMNParallelEnds(t *testing.T) {
+	pn := ImportBPMN("testdata/parallel-ends.bpmn")
+	sink := ""
+	for _, place := range pn.Net.Page.Places {
+		if place.Name == "sink" {
+			sink = place.ID
+		}
+	}
+	for _, mp := range pn.Net.FinalMarking.MPlaces {
+		if mp.ID == sink && mp.TokenCount != "2" {
+			t.Errorf("expected 2 tokens in the sink, got %s", mp.TokenCount)
+		}
+	}
+	checkCosts(t, pn, map[string]int{"a,b": 0, "b,a": 0, "a": 1})
+}
+
+// checks the optimal alignment costs of the traces on the imported model
+func checkCosts(t *testing.T, pn PNML, costs map[string]int) {
+	t.Helper()
+	for trace, cost := range costs {
+		ta := pn.AlignTraces([][]string{strings.Split(trace, ",")}, nil,
+			DefaultProductOptions)[0]
+		if ta.Err != nil {
+			t.Fatal(ta.Err)
+		}
+		if ta.Cost != cost {
+			t.Errorf("%s: expected cost %d, got %d", trace, cost, ta.Cost)
+		}
+	}
+}
+
+func TestImportBPMNExclusive(t *testing.T) {
+	pn := ImportBPMN("testdata/exclusive.bpmn")
+	if _, visible := countType(&pn, MODEL); visible != 3 {
+		t.Errorf("expected 3 visible transitions, got %d", visible)
+	}
+	checkCosts(t, pn, map[string]int{"a,c": 0, "b,c": 0, "a,b,c": 1,
+		"c": 1, "a": 1})
+}
+
+// returns the panic of ImportBPMN on the process, "" if there is none
+func importBPMNError(t *testing.T, process string) (msg string) {
+	fn := filepath.Join(t.TempDir(), "model.bpmn")
+	WriteFile(fn, "<definitions xmlns=\"http://www.omg.org/spec/BPMN/"+
+		"20100524/MODEL\"><process id=\"p\">"+process+
+		"</process></definitions>")
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprint(r)
+		}
+	}()
+	ImportBPMN(fn)
+	return ""
+}
+
+func TestImportBPMNErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		process string
+		err     string
+	}{
+		{"no incoming flow", `<startEvent id="s"/><task id="a"/>
+			<task id="b"/><endEvent id="e"/>
+			<sequenceFlow id="f0" sourceRef="s" targetRef="a"/>
+			<sequenceFlow id="f1" sourceRef="a" targetRef="e"/>
+			<sequenceFlow id="f2" sourceRef="b" targetRef="e"/>`,
+			"BPMN node 'b' has no incoming sequence flow"},
+		// every iteration of the loop leaves a token before b
+		{"unbounded", `<startEvent id="s"/><exclusiveGateway id="j"/>
+			<parallelGateway id="split"/><task id="a"/><task id="b"/>
+			<endEvent id="e"/>
+			<sequenceFlow id="f0" sourceRef="s" targetRef="j"/>
+			<sequenceFlow id="f1" sourceRef="j" targetRef="split"/>
+			<sequenceFlow id="f2" sourceRef="split" targetRef="a"/>
+			<sequenceFlow id="f3" sourceRef="a" targetRef="j"/>
+			<sequenceFlow id="f4" sourceRef="split" targetRef="b"/>
+			<sequenceFlow id="f5" sourceRef="b" targetRef="e"/>`,
+			"Exceeded 10000 markings"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := importBPMNError(t, c.process); !strings.Contains(err,
+				c.err) {
+				t.Errorf("expected the error '%s', got '%s'", c.err, err)
+			}
+		})
+	}
+}
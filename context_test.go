@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// Concurrent use of the alignment and marking graph contexts, run with
+// go test -race to check for data races.
+
+const concurrency = 8
+
+// writes the product of the small net and the trace a,x,b, which the
+// recorded solver trace testdata/small-trace.txt is a trace of
+func writeSmallProduct(t *testing.T) string {
+	model, _ := readModel("testdata/small.pnml", DefaultSilentOptions)
+	pn := model.CreateProduct([]string{"a", "x", "b"}, nil,
+		DefaultProductOptions)
+	fn := filepath.Join(t.TempDir(), "syncmodel-0.pnml")
+	pn.WriteNet(FMTPNML, fn)
+	return fn
+}
+
+func reconstruct(syncmodelfn string) (AlignmentS, error) {
+	ctx, err := NewAlignContext(syncmodelfn)
+	if err != nil {
+		return AlignmentS{}, err
+	}
+	trace, err := ReadTrace("testdata/small-trace.txt")
+	if err != nil {
+		return AlignmentS{}, err
+	}
+	return ctx.Reconstruct(trace)
+}
+
+func TestAlignContextIndependent(t *testing.T) {
+	fn := writeSmallProduct(t)
+	first, err := reconstruct(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := reconstruct(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Pairs) != 3 {
+		t.Fatalf("expected 3 pairs, got:\n%s", first.toString())
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("alignments of separate contexts differ:\n%s\n%s",
+			first.toString(), second.toString())
+	}
+}
+
+// a second trace replaces the first one instead of extending it
+func TestAlignContextReuse(t *testing.T) {
+	ctx, err := NewAlignContext(writeSmallProduct(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace, err := ReadTrace("testdata/small-trace.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := ctx.Reconstruct(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ctx.Reconstruct(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) || len(ctx.Trace) != len(trace) {
+		t.Errorf("the second trace extended the first:\n%s",
+			second.toString())
+	}
+}
+
+func TestAlignContextConcurrent(t *testing.T) {
+	fn := writeSmallProduct(t)
+	expected, err := reconstruct(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			al, err := reconstruct(fn)
+			if err == nil && !reflect.DeepEqual(al, expected) {
+				err = fmt.Errorf("alignment differs:\n%s", al.toString())
+			}
+			if err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestMarkingGraphConcurrent(t *testing.T) {
+	model, _ := readModel("testdata/small.pnml", DefaultSilentOptions)
+	expected := model.CreateMarkingGraph()
+	for i, m := range expected.Markings {
+		if m.ID != i {
+			t.Fatalf("marking %d has ID %d", i, m.ID)
+		}
+	}
+	var wg sync.WaitGroup
+	graphs := make([]MarkingGraph, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			graphs[i] = model.CreateMarkingGraph()
+		}(i)
+	}
+	wg.Wait()
+	for i, mg := range graphs {
+		if !reflect.DeepEqual(mg, expected) {
+			t.Errorf("marking graph %d differs: %v", i, mg)
+		}
+	}
+}
+
+func TestOptimalAlignmentsConcurrent(t *testing.T) {
+	model, _ := readModel("testdata/small.pnml", DefaultSilentOptions)
+	logtraces := [][]string{{"a", "x", "b"}, {"a", "b"}, {"b"}}
+	expected := model.AlignTraces(logtraces, nil, DefaultProductOptions)
+	var wg sync.WaitGroup
+	results := make([][]TraceAlignment, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = model.AlignTraces(logtraces, nil,
+				DefaultProductOptions)
+		}(i)
+	}
+	wg.Wait()
+	for i, tas := range results {
+		for j, ta := range tas {
+			if ta.Cost != expected[j].Cost ||
+				!reflect.DeepEqual(ta.Alignment, expected[j].Alignment) {
+				t.Errorf("run %d, trace %d: cost %d, expected %d", i, j,
+					ta.Cost, expected[j].Cost)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Size diagnostics of the synchronous products, computed from the model and
+// the log trace without constructing the product.
+
+type ProductStats struct {
+	Trace       int
+	Events      int
+	Places      int
+	Transitions int
+	Arcs        int
+	Sync        int      // number of sync transitions
+	Fanout      []int    // number of sync transitions per event
+	Labels      []string // event labels
+}
+
+// returns the size of the product as constructed by AddLog, excluding the
+// cost budget of AddCostBudget
+func (pn *PNML) EstimateProduct(logtrace []string,
+	labels *LabelMap) ProductStats {
+	ps := ProductStats{Events: len(logtrace),
+		Places:      len(pn.Net.Page.Places),
+		Transitions: len(pn.Net.Page.Transitions),
+		Arcs:        len(pn.Net.Page.Arcs)}
+	if len(logtrace) == 0 {
+		return ps
+	}
+	ps.Places += len(logtrace) + 1
+	ps.Transitions += len(logtrace)
+	ps.Arcs += 2 * len(logtrace)
+	for _, letter := range logtrace {
+		fanout := 0
+		for _, label := range labels.ModelLabels(letter) {
+			for _, ta := range pn.matchingModelTrans(label) {
+				fanout += 1
+				ps.Arcs += 2 + len(ta.In) + len(ta.Out)
+			}
+		}
+		ps.Fanout = append(ps.Fanout, fanout)
+		ps.Labels = append(ps.Labels, letter)
+		ps.Sync += fanout
+	}
+	ps.Transitions += ps.Sync
+	return ps
+}
+
+func (ps *ProductStats) Size() int {
+	return ps.Places + ps.Transitions + ps.Arcs
+}
+
+func (ps *ProductStats) MaxFanout() int {
+	ret := 0
+	for _, n := range ps.Fanout {
+		if n > ret {
+			ret = n
+		}
+	}
+	return ret
+}
+
+// events with a fan-out larger than one, as "index:label:fanout"
+func (ps *ProductStats) duplicates() string {
+	var parts []string
+	for i, n := range ps.Fanout {
+		if n > 1 {
+			parts = append(parts, fmt.Sprintf("%d:%s:%d", i, ps.Labels[i], n))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func DiagnosticsHeader() string {
+	return "trace,events,places,transitions,arcs,size,sync,maxfanout," +
+		"duplicates\n"
+}
+
+func (ps *ProductStats) toString() string {
+	return fmt.Sprintf("%d,%d,%d,%d,%d,%d,%d,%d,%s\n", ps.Trace, ps.Events,
+		ps.Places, ps.Transitions, ps.Arcs, ps.Size(), ps.Sync,
+		ps.MaxFanout(), ps.duplicates())
+}
+
+// returns the statistics of all products, or an error listing the products
+// exceeding maxSize (if positive)
+func (pn *PNML) DiagnoseProducts(logtraces [][]string, labels *LabelMap,
+	maxSize int) ([]ProductStats, error) {
+	var ret []ProductStats
+	var exceeding []string
+	for i, logtrace := range logtraces {
+		ps := pn.EstimateProduct(logtrace, labels)
+		ps.Trace = i
+		ret = append(ret, ps)
+		if maxSize > 0 && ps.Size() > maxSize {
+			exceeding = append(exceeding, fmt.Sprintf("%d (%d)", i, ps.Size()))
+		}
+	}
+	if len(exceeding) > 0 {
+		return ret, fmt.Errorf("Products exceed the maximal size %d: %s",
+			maxSize, strings.Join(exceeding, ", "))
+	}
+	return ret, nil
+}
+
+// prints the statistics of the products without constructing them
+func DiagnoseProducts(modelfn, logfn string, opts ProductOptions) {
+	CheckError(opts.Silent.Check())
+	model, _ := readModel(modelfn, opts.Silent)
+	logtraces := readLog(logfn)
+	labels := NewLabelMap(&model, opts.LabelFile, opts.Normalize)
+	stats, err := model.DiagnoseProducts(logtraces, labels, opts.MaxSize)
+	fmt.Print(DiagnosticsHeader())
+	for _, ps := range stats {
+		fmt.Print(ps.toString())
+	}
+	CheckError(err)
+}
@@ -0,0 +1,3 @@
+module github.com/vbloemen/pnmlprod
+
+go 1.20
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Tests of the product construction, properties, alignment reconstruction
+// and DOT output against the golden files in testdata/golden, which are
+// rewritten by: go test -update
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// compares got with the golden file, or rewrites it with -update
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	fn := filepath.Join("testdata", "golden", name)
+	if *update {
+		WriteFile(fn, got)
+		return
+	}
+	want, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the golden file:\n%s", name, got)
+	}
+}
+
+type productCase struct {
+	name     string
+	modelfn  string
+	logtrace []string
+}
+
+func smallProduct() productCase {
+	return productCase{"small", "testdata/small.pnml", []string{"a", "x", "b"}}
+}
+
+func modelProduct() productCase {
+	return productCase{"model", "model.pnml", readLog("log.xes")[0]}
+}
+
+func (pc productCase) product() (PNML, PNML) {
+	model, _ := readModel(pc.modelfn, DefaultSilentOptions)
+	return model, model.CreateProduct(pc.logtrace, nil, DefaultProductOptions)
+}
+
+func countType(pn *PNML, t string) (places, transitions int) {
+	for _, place := range pn.Net.Page.Places {
+		if place.Type == t {
+			places += 1
+		}
+	}
+	for _, trans := range pn.Net.Page.Transitions {
+		if trans.Type == t {
+			transitions += 1
+		}
+	}
+	return
+}
+
+func TestAddLogSmall(t *testing.T) {
+	_, pn := smallProduct().product()
+	page := &pn.Net.Page
+	if len(page.Places) != 7 || len(page.Transitions) != 8 ||
+		len(page.Arcs) != 20 {
+		t.Errorf("expected 7 places, 8 transitions and 20 arcs, got %d, %d"+
+			" and %d", len(page.Places), len(page.Transitions),
+			len(page.Arcs))
+	}
+	ids := make(map[string]string)
+	for _, place := range page.Places {
+		ids[place.ID] = place.Type
+	}
+	for _, trans := range page.Transitions {
+		ids[trans.ID] = trans.Type
+	}
+	for id, typ := range map[string]string{"logp0": LOG, "logp3": LOG,
+		"logt0": LOG, "logt1": LOG, "logt2": LOG, "logs0n0": SYNC,
+		"logs2n0": SYNC, "t1": MODEL, "t3": TAU} {
+		if ids[id] != typ {
+			t.Errorf("%s: expected type %s, got '%s'", id, typ, ids[id])
+		}
+	}
+	if _, ok := ids["logs1n0"]; ok {
+		t.Errorf("unexpected sync transition for the unmatched event x")
+	}
+	var final []string
+	for _, mp := range pn.Net.FinalMarking.MPlaces {
+		final = append(final, mp.ID+"="+mp.TokenCount)
+	}
+	if got := strings.Join(final, ","); got !=
+		"p3=1,logp0=0,logp1=0,logp2=0,logp3=1" {
+		t.Errorf("unexpected final marking %s", got)
+	}
+}
+
+func TestAddLogModel(t *testing.T) {
+	pc := modelProduct()
+	model, pn := pc.product()
+	n := len(pc.logtrace)
+	if n != 7 {
+		t.Fatalf("expected a log trace of 7 events, got %d", n)
+	}
+	logPlaces, logTrans := countType(&pn, LOG)
+	_, syncTrans := countType(&pn, SYNC)
+	if logPlaces != n+1 || logTrans != n || syncTrans != 15 {
+		t.Errorf("expected %d log places, %d log and 15 sync transitions,"+
+			" got %d, %d and %d", n+1, n, logPlaces, logTrans, syncTrans)
+	}
+	page := &pn.Net.Page
+	if len(page.Places) != 84 || len(page.Transitions) != 99 ||
+		len(page.Arcs) != 316 {
+		t.Errorf("expected 84 places, 99 transitions and 316 arcs, got %d,"+
+			" %d and %d", len(page.Places), len(page.Transitions),
+			len(page.Arcs))
+	}
+	final := pn.Net.FinalMarking.MPlaces
+	if len(final) != len(model.Net.FinalMarking.MPlaces)+n+1 {
+		t.Errorf("expected %d final marking places, got %d",
+			len(model.Net.FinalMarking.MPlaces)+n+1, len(final))
+	}
+	if last := final[len(final)-1]; last.ID != fmt.Sprintf("logp%d", n) ||
+		last.TokenCount != "1" {
+		t.Errorf("unexpected last final marking place %v", last)
+	}
+}
+
+func TestProductGolden(t *testing.T) {
+	for _, pc := range []productCase{smallProduct(), modelProduct()} {
+		t.Run(pc.name, func(t *testing.T) {
+			_, pn := pc.product()
+			checkGolden(t, pc.name+"-product.pnml", pn.ToPNML())
+			checkGolden(t, pc.name+"-invariant.txt", pn.GenerateInvariant())
+		})
+	}
+}
+
+func TestTraceToAlignGolden(t *testing.T) {
+	cases := []struct {
+		pc      productCase
+		tracefn string
+		golden  string
+	}{
+		{smallProduct(), "testdata/small-trace.txt", "small-alignment.txt"},
+		{smallProduct(), "testdata/small-trace.csv", "small-alignment.txt"},
+		{smallProduct(), "testdata/small-trace.json", "small-alignment.txt"},
+		{modelProduct(), "testdata/model-trace-0.txt", "model-alignment.txt"},
+	}
+	for _, c := range cases {
+		t.Run(filepath.Base(c.tracefn), func(t *testing.T) {
+			model, pn := c.pc.product()
+			fn := filepath.Join(t.TempDir(), "syncmodel-0.pnml")
+			pn.WriteNet(FMTPNML, fn)
+			ctx, err := NewAlignContext(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			trace, err := ReadTrace(c.tracefn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ctx.Reconstruct(trace); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, c.golden, ctx.Alignment.toString())
+			// the reconstructed alignment should be valid and optimal
+			cost, err := model.ValidateAlignment(c.pc.logtrace,
+				ctx.Alignment, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			optimal, _, err := pn.OptimalAlignments(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cost != optimal {
+				t.Errorf("cost %d, the optimal cost is %d", cost, optimal)
+			}
+		})
+	}
+}
+
+func TestPrintDOTGolden(t *testing.T) {
+	for _, pc := range []productCase{smallProduct(), modelProduct()} {
+		t.Run(pc.name, func(t *testing.T) {
+			model, pn := pc.product()
+			dir := t.TempDir()
+			model.PrintDOT(filepath.Join(dir, "model.dot"))
+			pn.PrintDOT(filepath.Join(dir, "product.dot"))
+			for _, name := range []string{"model.dot", "product.dot"} {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkGolden(t, pc.name+"-"+name, string(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Reading models in different formats into the internal PNML structure. The
+// importers for non-PNML formats fill in the types (MODEL/TAU) and the final
+// marking themselves, such that the result is equal to a post-processed PNML.
+
+// reads a model from a .pnml, .bpmn or .ptml file, returns the model and its
+// silent transitions
+func readModel(modelfn string, silent SilentOptions) (PNML,
+	[]SilentTransition) {
+	var pn PNML
+	switch {
+	case strings.HasSuffix(modelfn, ".bpmn"):
+		pn = ImportBPMN(modelfn)
+	case strings.HasSuffix(modelfn, ".ptml"):
+		pn = ImportPTML(modelfn)
+	default:
+		modelcontents := readPNML(modelfn)
+		xml.Unmarshal(modelcontents, &pn)         // fill in PNML contents
+		silentTrans := pn.PostProcessPNML(silent) // fill in markings etc.
+		return pn, silentTrans
+	}
+	// silent transitions follow from the structure of the imported model
+	var ret []SilentTransition
+	for _, trans := range pn.Net.Page.Transitions {
+		if trans.Type == TAU {
+			ret = append(ret, SilentTransition{ID: trans.ID, Name: trans.Name,
+				Reason: "import"})
+		}
+	}
+	return pn, ret
+}
+
+// Reads a model or a synchronous product written by -p, whose move types
+// are kept
+func readNet(netfn string) PNML {
+	if strings.HasSuffix(netfn, ".pnml") {
+		var pn PNML
+		CheckError(xml.Unmarshal(readPNML(netfn), &pn))
+		for _, trans := range pn.Net.Page.Transitions {
+			if trans.Type != "" {
+				return pn
+			}
+		}
+	}
+	pn, _ := readModel(netfn, DefaultSilentOptions)
+	return pn
+}
+
+// returns a copy of the net that can be modified independently
+func (pn *PNML) Copy() PNML {
+	ret := *pn
+	ret.Net.Page.Places = append([]Place{}, pn.Net.Page.Places...)
+	ret.Net.Page.Transitions = append([]Transition{},
+		pn.Net.Page.Transitions...)
+	ret.Net.Page.Arcs = append([]Arc{}, pn.Net.Page.Arcs...)
+	ret.Net.FinalMarking.MPlaces = append([]MPlace{},
+		pn.Net.FinalMarking.MPlaces...)
+	return ret
+}
+
+// netBuilder constructs a post-processed PNML with generated IDs
+type netBuilder struct {
+	pn    PNML
+	count int
+}
+
+func newNetBuilder(name string) *netBuilder {
+	nb := &netBuilder{}
+	nb.pn.Net.ID = "net1"
+	nb.pn.Net.Type = "http://www.pnml.org/version-2009/grammar/pnmlcoremodel"
+	nb.pn.Net.Name = name
+	nb.pn.Net.Page.ID = "n0"
+	return nb
+}
+
+func (nb *netBuilder) nextID() string {
+	nb.count += 1
+	return fmt.Sprintf("n%d", nb.count)
+}
+
+func (nb *netBuilder) addPlace(name string) string {
+	id := nb.nextID()
+	p := &Place{XMLName: xml.Name{Space: "", Local: "place"},
+		ID: id, Name: name, InitialMarking: "0", Type: MODEL}
+	nb.pn.Net.Page.Places = append(nb.pn.Net.Page.Places, *p)
+	return id
+}
+
+// adds a transition, an empty name results in a TAU transition
+func (nb *netBuilder) addTransition(name string, in, out []string) string {
+	id := nb.nextID()
+	t := &Transition{XMLName: xml.Name{Space: "", Local: "transition"},
+		ID: id, Name: name, Type: MODEL}
+	if name == "" {
+		t.Name = TAUSYM
+		t.Type = TAU
+	}
+	t.OrigName = t.Name
+	nb.pn.Net.Page.Transitions = append(nb.pn.Net.Page.Transitions, *t)
+	for _, place := range in {
+		nb.addArc(place, id)
+	}
+	for _, place := range out {
+		nb.addArc(id, place)
+	}
+	return id
+}
+
+func (nb *netBuilder) addArc(source, target string) {
+	id := nb.nextID()
+	a := &Arc{XMLName: xml.Name{Space: "", Local: "arc"},
+		ID: id, Name: id, Source: source, Target: target}
+	nb.pn.Net.Page.Arcs = append(nb.pn.Net.Page.Arcs, *a)
+}
+
+func (nb *netBuilder) setMarking(initial, final string) {
+	for i, place := range nb.pn.Net.Page.Places {
+		if place.ID == initial {
+			nb.pn.Net.Page.Places[i].InitialMarking = "1"
+		}
+		count := "0"
+		if place.ID == final {
+			count = "1"
+		}
+		mp := &MPlace{XMLName: xml.Name{Space: "", Local: "place"},
+			ID: place.ID, TokenCount: count}
+		nb.pn.Net.FinalMarking.MPlaces = append(
+			nb.pn.Net.FinalMarking.MPlaces, *mp)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Structural analysis of the net: P-semiflows (place invariants) and
+// T-semiflows (transition invariants) computed with the Farkas algorithm on
+// the incidence matrix.
+
+type IncidenceMatrix struct {
+	Places      []string // place IDs, row index
+	Transitions []string // transition IDs, column index
+	C           [][]int  // C[p][t] = tokens produced - tokens consumed
+}
+
+type Semiflows struct {
+	Nodes   []string // IDs the vectors range over (places or transitions)
+	Vectors [][]int
+}
+
+// limit on the number of intermediate rows, the Farkas algorithm is
+// exponential in the worst case (a variable for the tests)
+var FARKASLIMIT int = 100000
+
+func (pn *PNML) CreateIncidenceMatrix() IncidenceMatrix {
+	im := IncidenceMatrix{}
+	placeMap := make(map[string]int)
+	transMap := make(map[string]int)
+	for i, place := range pn.Net.Page.Places {
+		im.Places = append(im.Places, place.ID)
+		placeMap[place.ID] = i
+	}
+	for i, trans := range pn.Net.Page.Transitions {
+		im.Transitions = append(im.Transitions, trans.ID)
+		transMap[trans.ID] = i
+	}
+	im.C = make([][]int, len(im.Places))
+	for i, _ := range im.C {
+		im.C[i] = make([]int, len(im.Transitions))
+	}
+	// NB: parallel arcs are counted as arc weights
+	for _, arc := range pn.Net.Page.Arcs {
+		if p, ok := placeMap[arc.Source]; ok {
+			if t, ok := transMap[arc.Target]; ok {
+				im.C[p][t] -= 1
+			}
+		} else if p, ok := placeMap[arc.Target]; ok {
+			if t, ok := transMap[arc.Source]; ok {
+				im.C[p][t] += 1
+			}
+		}
+	}
+	return im
+}
+
+// returns the transposed matrix
+func (im *IncidenceMatrix) transpose() [][]int {
+	ret := make([][]int, len(im.Transitions))
+	for t, _ := range ret {
+		ret[t] = make([]int, len(im.Places))
+		for p, _ := range im.Places {
+			ret[t][p] = im.C[p][t]
+		}
+	}
+	return ret
+}
+
+func (im *IncidenceMatrix) PSemiflows() (Semiflows, error) {
+	vectors, err := farkas(im.C)
+	return Semiflows{Nodes: im.Places, Vectors: vectors}, err
+}
+
+func (im *IncidenceMatrix) TSemiflows() (Semiflows, error) {
+	vectors, err := farkas(im.transpose())
+	return Semiflows{Nodes: im.Transitions, Vectors: vectors}, err
+}
+
+// Farkas algorithm: computes a generating set of the minimal non-negative
+// integer solutions y of y.A = 0, where A is an n x m matrix
+func farkas(A [][]int) ([][]int, error) {
+	n := len(A)
+	if n == 0 {
+		return nil, nil
+	}
+	m := len(A[0])
+	// each row is [A | I], the identity part records the combination
+	var D [][]int
+	for i := 0; i < n; i++ {
+		row := make([]int, m+n)
+		copy(row, A[i])
+		row[m+i] = 1
+		D = append(D, row)
+	}
+	for j := 0; j < m; j++ {
+		var next [][]int
+		var pos, neg []int
+		for i, row := range D {
+			if row[j] == 0 {
+				next = append(next, row)
+			} else if row[j] > 0 {
+				pos = append(pos, i)
+			} else {
+				neg = append(neg, i)
+			}
+		}
+		for _, pi := range pos {
+			for _, ni := range neg {
+				a := D[pi][j]
+				b := -D[ni][j]
+				row := make([]int, m+n)
+				for k, _ := range row {
+					row[k] = b*D[pi][k] + a*D[ni][k]
+				}
+				normalize(row)
+				next = append(next, row)
+			}
+			if len(next) > FARKASLIMIT {
+				return nil, fmt.Errorf("Farkas algorithm exceeded %d rows",
+					FARKASLIMIT)
+			}
+		}
+		D = removeNonMinimal(next, m)
+	}
+	var ret [][]int
+	for _, row := range D {
+		ret = append(ret, row[m:])
+	}
+	return ret, nil
+}
+
+func gcd(a, b int) int {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// divides all entries by their greatest common divisor
+func normalize(row []int) {
+	g := 0
+	for _, n := range row {
+		g = gcd(g, n)
+	}
+	if g > 1 {
+		for i, _ := range row {
+			row[i] /= g
+		}
+	}
+}
+
+// removes duplicate rows and rows whose support (starting at offset) is a
+// strict superset of the support of another row
+func removeNonMinimal(D [][]int, offset int) [][]int {
+	var ret [][]int
+	for i, a := range D {
+		minimal := true
+		for j, b := range D {
+			if i == j {
+				continue
+			}
+			if supportSubset(b, a, offset) {
+				if !supportSubset(a, b, offset) || j < i {
+					// strict subset, or equal support and seen before
+					minimal = false
+					break
+				}
+			}
+		}
+		if minimal {
+			ret = append(ret, a)
+		}
+	}
+	return ret
+}
+
+// returns whether the support of a is contained in the support of b
+func supportSubset(a, b []int, offset int) bool {
+	for k := offset; k < len(a); k++ {
+		if a[k] != 0 && b[k] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// returns the node IDs that are not in the support of any semiflow
+func (sf *Semiflows) Uncovered() []string {
+	var ret []string
+	for i, node := range sf.Nodes {
+		covered := false
+		for _, v := range sf.Vectors {
+			if v[i] != 0 {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			ret = append(ret, node)
+		}
+	}
+	return ret
+}
+
+func (sf *Semiflows) vectorToString(v []int) string {
+	var parts []string
+	for i, n := range v {
+		if n == 1 {
+			parts = append(parts, sf.Nodes[i])
+		} else if n != 0 {
+			parts = append(parts, fmt.Sprintf("%d*%s", n, sf.Nodes[i]))
+		}
+	}
+	return strings.Join(parts, " + ")
+}
+
+func (sf *Semiflows) toString() string {
+	ret := ""
+	for _, v := range sf.Vectors {
+		ret += sf.vectorToString(v) + "\n"
+	}
+	return ret
+}
+
+// Returns the P-invariants as an LTSmin invariant, i.e. for each P-semiflow
+// y the weighted token sum y.M equals y.M0 in every reachable marking M
+func (pn *PNML) GeneratePInvariant(sf Semiflows) string {
+	initial := make(map[string]int)
+	for _, place := range pn.Net.Page.Places {
+		count, _ := strconv.Atoi(place.InitialMarking)
+		initial[place.ID] = count
+	}
+	var parts []string
+	for _, v := range sf.Vectors {
+		sum := 0
+		for i, n := range v {
+			sum += n * initial[sf.Nodes[i]]
+		}
+		parts = append(parts, fmt.Sprintf("%s == %d", sf.vectorToString(v),
+			sum))
+	}
+	return strings.Join(parts, " && ")
+}
+
+// returns the semiflows of the net and whether it is covered by P-invariants
+func (pn *PNML) InvariantReport() (string, Semiflows, error) {
+	im := pn.CreateIncidenceMatrix()
+	psf, err := im.PSemiflows()
+	if err != nil {
+		return "", psf, err
+	}
+	tsf, err := im.TSemiflows()
+	if err != nil {
+		return "", psf, err
+	}
+	ret := fmt.Sprintf("P-semiflows (%d):\n%s", len(psf.Vectors),
+		psf.toString())
+	ret += fmt.Sprintf("T-semiflows (%d):\n%s", len(tsf.Vectors),
+		tsf.toString())
+	uncovered := psf.Uncovered()
+	if len(uncovered) == 0 {
+		ret += "The net is covered by P-invariants, hence bounded\n"
+	} else {
+		ret += fmt.Sprintf("Places not covered by P-invariants: %s\n",
+			strings.Join(uncovered, ", "))
+	}
+	return ret, psf, nil
+}
+
+func CheckInvariants(modelfn, invariantfn string) {
+	modelcontents := readPNML(modelfn)
+	var pn PNML
+	xml.Unmarshal(modelcontents, &pn) // fill in PNML contents
+
+	report, psf, err := pn.InvariantReport()
+	CheckError(err)
+	fmt.Print(report)
+	if invariantfn != "" && len(psf.Vectors) > 0 {
+		WriteFile(invariantfn, pn.GeneratePInvariant(psf))
+	}
+}
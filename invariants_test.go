@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func readTestNet(t *testing.T, fn string) PNML {
+	var pn PNML
+	if err := xml.Unmarshal(readPNML(fn), &pn); err != nil {
+		t.Fatal(err)
+	}
+	return pn
+}
+
+func TestFarkas(t *testing.T) {
+	// y.A = 0 for the rows y = (1,1,0) and (0,1,1)
+	A := [][]int{{1, -1}, {-1, 1}, {1, -1}}
+	vectors, err := farkas(A)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]int{{1, 1, 0}, {0, 1, 1}}
+	if !reflect.DeepEqual(vectors, expected) {
+		t.Errorf("expected %v, got %v", expected, vectors)
+	}
+}
+
+func TestFarkasLimit(t *testing.T) {
+	defer func(limit int) { FARKASLIMIT = limit }(FARKASLIMIT)
+	FARKASLIMIT = 3
+	// 2 positive and 2 negative rows combine to 4 rows
+	A := [][]int{{1}, {1}, {-1}, {-1}}
+	if _, err := farkas(A); err == nil {
+		t.Errorf("expected an error for exceeding %d rows", FARKASLIMIT)
+	}
+}
+
+func TestInvariantReport(t *testing.T) {
+	cases := []struct {
+		fn        string
+		report    string
+		invariant string
+	}{
+		// a cycle with an arc weight of 2 and a source transition into p3
+		{"testdata/weighted.pnml", "P-semiflows (1):\np1 + 2*p2\n" +
+			"T-semiflows (1):\nt1 + t2\n" +
+			"Places not covered by P-invariants: p3\n", "p1 + 2*p2 == 2"},
+		{"testdata/small.pnml", "P-semiflows (1):\np1 + p2 + p3\n" +
+			"T-semiflows (1):\nt1 + t3\n" +
+			"The net is covered by P-invariants, hence bounded\n",
+			"p1 + p2 + p3 == 1"},
+	}
+	for _, c := range cases {
+		t.Run(c.fn, func(t *testing.T) {
+			pn := readTestNet(t, c.fn)
+			report, psf, err := pn.InvariantReport()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report != c.report {
+				t.Errorf("unexpected report:\n%s", report)
+			}
+			if inv := pn.GeneratePInvariant(psf); inv != c.invariant {
+				t.Errorf("expected the invariant %s, got %s", c.invariant,
+					inv)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Mapping between log activities and model transition labels, used by AddLog
+// to decide which model transitions can synchronize with a log event.
+
+type LabelMap struct {
+	Normalize   bool                // case-insensitive, normalized matching
+	Mapping     map[string][]string // (normalized) activity -> model labels
+	modelLabels map[string][]string // (normalized) label -> model labels
+}
+
+// lower case, '_' and '-' as spaces and without repeated white space
+func normalizeLabel(label string) string {
+	label = strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' {
+			return ' '
+		}
+		return unicode.ToLower(r)
+	}, label)
+	return strings.Join(strings.Fields(label), " ")
+}
+
+func (lm *LabelMap) key(label string) string {
+	if lm.Normalize {
+		return normalizeLabel(label)
+	}
+	return label
+}
+
+// Creates the label mapping for the (post-processed) model. The optional
+// mapping file is a CSV file with lines "activity,model label"; an activity
+// may occur on multiple lines and multiple activities may map to the same
+// model label.
+func NewLabelMap(pn *PNML, mappingfn string, normalize bool) *LabelMap {
+	lm := &LabelMap{Normalize: normalize,
+		Mapping:     make(map[string][]string),
+		modelLabels: make(map[string][]string)}
+	for _, label := range pn.modelLabels() {
+		k := lm.key(label)
+		lm.modelLabels[k] = append(lm.modelLabels[k], label)
+	}
+	if mappingfn == "" {
+		return lm
+	}
+	file, err := os.Open(mappingfn)
+	CheckError(err)
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	CheckError(err)
+	for i, record := range records {
+		if len(record) != 2 {
+			CheckError(errors.New(fmt.Sprintf("%s:%d: expected 'activity,"+
+				"model label'", mappingfn, i+1)))
+		}
+		k := lm.key(record[0])
+		for _, label := range lm.modelLabels[lm.key(record[1])] {
+			if !lm.maps(k, label) { // repeated lines
+				lm.Mapping[k] = append(lm.Mapping[k], label)
+			}
+		}
+		if len(lm.modelLabels[lm.key(record[1])]) == 0 {
+			CheckError(errors.New(fmt.Sprintf("%s:%d: no model transition"+
+				" with label '%s'", mappingfn, i+1, record[1])))
+		}
+	}
+	return lm
+}
+
+func (lm *LabelMap) maps(k, label string) bool {
+	for _, l := range lm.Mapping[k] {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the model labels the activity synchronizes with
+func (lm *LabelMap) ModelLabels(activity string) []string {
+	if lm == nil {
+		return []string{activity}
+	}
+	k := lm.key(activity)
+	if labels, ok := lm.Mapping[k]; ok {
+		return labels
+	}
+	return lm.modelLabels[k]
+}
+
+// returns the sorted, distinct labels of the visible model transitions
+func (pn *PNML) modelLabels() []string {
+	seen := make(map[string]bool)
+	var ret []string
+	for _, trans := range pn.Net.Page.Transitions {
+		if trans.Type == MODEL && !seen[trans.Name] {
+			seen[trans.Name] = true
+			ret = append(ret, trans.Name)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func levenshtein(a, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j, _ := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// returns up to three model labels close to the activity
+func (lm *LabelMap) Suggestions(pn *PNML, activity string) []string {
+	a := normalizeLabel(activity)
+	maxDist := len([]rune(a)) / 3
+	if maxDist < 1 {
+		maxDist = 1
+	}
+	var ret []string
+	dist := make(map[string]int)
+	for _, label := range pn.modelLabels() {
+		d := levenshtein(a, normalizeLabel(label))
+		if d <= maxDist {
+			ret = append(ret, label)
+			dist[label] = d
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return dist[ret[i]] < dist[ret[j]]
+	})
+	if len(ret) > 3 {
+		ret = ret[:3]
+	}
+	return ret
+}
+
+// Reports the log activities without a model counterpart (with suggestions)
+// and the model labels that no log activity is mapped to
+func (lm *LabelMap) Report(pn *PNML, logtraces [][]string) string {
+	activities := make(map[string]bool)
+	for _, trace := range logtraces {
+		for _, activity := range trace {
+			activities[activity] = true
+		}
+	}
+	var sorted []string
+	for activity, _ := range activities {
+		sorted = append(sorted, activity)
+	}
+	sort.Strings(sorted)
+
+	seen := make(map[string]bool)
+	ret := "Log activities without model transition:\n"
+	for _, activity := range sorted {
+		labels := lm.ModelLabels(activity)
+		for _, label := range labels {
+			seen[label] = true
+		}
+		if len(labels) == 0 {
+			ret += "  " + activity
+			if sugg := lm.Suggestions(pn, activity); len(sugg) > 0 {
+				ret += " (did you mean: " + strings.Join(sugg, ", ") + "?)"
+			}
+			ret += "\n"
+		}
+	}
+	ret += "Model transitions never seen in the log:\n"
+	for _, label := range pn.modelLabels() {
+		if !seen[label] {
+			ret += "  " + label + "\n"
+		}
+	}
+	return ret
+}
@@ -0,0 +1,735 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Layered (Sugiyama-style) layout of directed graphs from left to right, as
+// rankdir=LR in the DOT output, and an SVG writer, to draw nets and marking
+// graphs without graphviz. The layout removes cycles by reversing the back
+// edges of a depth-first search, assigns layers by longest paths, splits long
+// edges with dummy nodes, orders the layers by barycenter sweeps and places
+// the nodes of each layer close to the average of their neighbours.
+
+const (
+	LAYOUTRANKSEP    float64 = 60 // horizontal space between layers
+	LAYOUTNODESEP    float64 = 20 // vertical space between nodes of a layer
+	LAYOUTMARGIN     float64 = 20
+	LAYOUTFONTSIZE   float64 = 12
+	LAYOUTCHARWIDTH  float64 = 7.2 // of the bold monospace font
+	LAYOUTLINEHEIGHT float64 = 14
+	LAYOUTSWEEPS     int     = 24 // barycenter sweeps of crossing reduction
+	LAYOUTPASSES     int     = 8  // passes of the vertical placement
+)
+
+// drawings of the model and products
+const (
+	DRAWDOT string = "dot"
+	DRAWSVG string = "svg"
+)
+
+var (
+	DRAWINGS []string = []string{DRAWDOT, DRAWSVG}
+)
+
+func CheckDrawing(drawing string) error {
+	for _, d := range DRAWINGS {
+		if d == drawing {
+			return nil
+		}
+	}
+	return errors.New("Unknown drawing format '" + drawing + "'")
+}
+
+const SVGHEADER string = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"
+
+// node shapes
+const (
+	SHAPECIRCLE  string = "circle"
+	SHAPEBOX     string = "box"
+	SHAPEROUNDED string = "rounded"
+)
+
+type layoutNode struct {
+	ID     string
+	Lines  []string // label
+	Shape  string
+	Fill   string // graphviz colour
+	Dashed bool
+	// computed by Layout
+	dummy      bool
+	layer      int
+	x, y, w, h float64 // center and size
+}
+
+type layoutEdge struct {
+	Source int
+	Target int
+	Label  string
+	Color  string // graphviz colour
+	Dashed bool
+	Arrow  bool
+	// computed by Layout
+	reversed bool
+	route    []int // nodes from the (reversed) source to target
+	points   [][2]float64
+}
+
+type layoutGraph struct {
+	Nodes  []layoutNode
+	Edges  []layoutEdge
+	Prefix string // of the SVG element IDs, for several SVGs in a document
+	index  map[string]int
+	width  float64
+	height float64
+}
+
+func newLayoutGraph() *layoutGraph {
+	return &layoutGraph{index: make(map[string]int)}
+}
+
+func (g *layoutGraph) addNode(node layoutNode) {
+	g.index[node.ID] = len(g.Nodes)
+	g.Nodes = append(g.Nodes, node)
+}
+
+// adds an edge between the nodes with the IDs, if both exist
+func (g *layoutGraph) addEdge(source, target string, edge layoutEdge) {
+	s, ok1 := g.index[source]
+	t, ok2 := g.index[target]
+	if !ok1 || !ok2 {
+		return
+	}
+	edge.Source = s
+	edge.Target = t
+	g.Edges = append(g.Edges, edge)
+}
+
+func textWidth(s string) float64 {
+	return float64(utf8.RuneCountInString(s)) * LAYOUTCHARWIDTH
+}
+
+func (node *layoutNode) setSize() {
+	if node.dummy {
+		return
+	}
+	w := 0.0
+	for _, line := range node.Lines {
+		w = math.Max(w, textWidth(line))
+	}
+	h := float64(len(node.Lines)) * LAYOUTLINEHEIGHT
+	if node.Shape == SHAPECIRCLE {
+		d := math.Max(math.Max(w, h)+12, 30)
+		node.w, node.h = d, d
+	} else {
+		node.w = math.Max(w+16, 40)
+		node.h = math.Max(h+12, 30)
+	}
+}
+
+// reverses the back edges of a depth-first search, sources first
+func (g *layoutGraph) removeCycles() {
+	out := make([][]int, len(g.Nodes))
+	hasIn := make([]bool, len(g.Nodes))
+	for ei, edge := range g.Edges {
+		if edge.Source != edge.Target {
+			out[edge.Source] = append(out[edge.Source], ei)
+			hasIn[edge.Target] = true
+		}
+	}
+	state := make([]int, len(g.Nodes)) // 0 new, 1 on stack, 2 done
+	var dfs func(v int)
+	dfs = func(v int) {
+		state[v] = 1
+		for _, ei := range out[v] {
+			w := g.Edges[ei].Target
+			if state[w] == 1 {
+				g.Edges[ei].reversed = true
+			} else if state[w] == 0 {
+				dfs(w)
+			}
+		}
+		state[v] = 2
+	}
+	for v, _ := range g.Nodes {
+		if !hasIn[v] && state[v] == 0 {
+			dfs(v)
+		}
+	}
+	for v, _ := range g.Nodes {
+		if state[v] == 0 {
+			dfs(v)
+		}
+	}
+}
+
+// returns the source and target of the edge in the acyclic graph
+func (edge *layoutEdge) ends() (int, int) {
+	if edge.reversed {
+		return edge.Target, edge.Source
+	}
+	return edge.Source, edge.Target
+}
+
+// assigns the layers by longest paths from the sources
+func (g *layoutGraph) assignLayers() {
+	indeg := make([]int, len(g.Nodes))
+	out := make([][]int, len(g.Nodes))
+	for _, edge := range g.Edges {
+		if edge.Source == edge.Target {
+			continue
+		}
+		s, t := edge.ends()
+		out[s] = append(out[s], t)
+		indeg[t] += 1
+	}
+	var Q []int
+	for v, _ := range g.Nodes {
+		if indeg[v] == 0 {
+			Q = append(Q, v)
+		}
+	}
+	for len(Q) > 0 {
+		v := Q[0]
+		Q = Q[1:]
+		for _, w := range out[v] {
+			if g.Nodes[v].layer+1 > g.Nodes[w].layer {
+				g.Nodes[w].layer = g.Nodes[v].layer + 1
+			}
+			indeg[w] -= 1
+			if indeg[w] == 0 {
+				Q = append(Q, w)
+			}
+		}
+	}
+}
+
+// splits the edges spanning several layers with dummy nodes
+func (g *layoutGraph) addDummies() {
+	for ei, _ := range g.Edges {
+		edge := &g.Edges[ei]
+		s, t := edge.ends()
+		edge.route = []int{s}
+		if s != t {
+			for l := g.Nodes[s].layer + 1; l < g.Nodes[t].layer; l++ {
+				edge.route = append(edge.route, len(g.Nodes))
+				g.Nodes = append(g.Nodes, layoutNode{dummy: true, layer: l})
+			}
+			edge.route = append(edge.route, t)
+		}
+	}
+}
+
+// returns the number of crossings between consecutive layers
+func layerCrossings(layers [][]int, pos []int, succ [][]int) int {
+	ret := 0
+	for l := 0; l+1 < len(layers); l++ {
+		var segs [][2]int
+		for _, v := range layers[l] {
+			for _, w := range succ[v] {
+				segs = append(segs, [2]int{pos[v], pos[w]})
+			}
+		}
+		for i := 0; i < len(segs); i++ {
+			for j := i + 1; j < len(segs); j++ {
+				if (segs[i][0]-segs[j][0])*(segs[i][1]-segs[j][1]) < 0 {
+					ret += 1
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// orders the nodes of each layer by barycenter sweeps, keeping the order
+// with the fewest crossings
+func (g *layoutGraph) orderLayers() [][]int {
+	nlayers := 0
+	for _, node := range g.Nodes {
+		if node.layer+1 > nlayers {
+			nlayers = node.layer + 1
+		}
+	}
+	layers := make([][]int, nlayers)
+	pos := make([]int, len(g.Nodes))
+	for v, node := range g.Nodes {
+		pos[v] = len(layers[node.layer])
+		layers[node.layer] = append(layers[node.layer], v)
+	}
+	pred := make([][]int, len(g.Nodes))
+	succ := make([][]int, len(g.Nodes))
+	for _, edge := range g.Edges {
+		for i := 0; i+1 < len(edge.route); i++ {
+			v, w := edge.route[i], edge.route[i+1]
+			succ[v] = append(succ[v], w)
+			pred[w] = append(pred[w], v)
+		}
+	}
+	sortLayer := func(layer []int, adj [][]int) {
+		bary := make(map[int]float64)
+		for _, v := range layer {
+			bary[v] = float64(pos[v])
+			if len(adj[v]) > 0 {
+				sum := 0
+				for _, w := range adj[v] {
+					sum += pos[w]
+				}
+				bary[v] = float64(sum) / float64(len(adj[v]))
+			}
+		}
+		sort.SliceStable(layer, func(i, j int) bool {
+			return bary[layer[i]] < bary[layer[j]]
+		})
+		for i, v := range layer {
+			pos[v] = i
+		}
+	}
+	copyLayers := func() [][]int {
+		ret := make([][]int, len(layers))
+		for l, layer := range layers {
+			ret[l] = append([]int{}, layer...)
+		}
+		return ret
+	}
+	best := copyLayers()
+	bestCrossings := layerCrossings(layers, pos, succ)
+	for i := 0; i < LAYOUTSWEEPS && bestCrossings > 0; i++ {
+		if i%2 == 0 {
+			for l := 1; l < len(layers); l++ {
+				sortLayer(layers[l], pred)
+			}
+		} else {
+			for l := len(layers) - 2; l >= 0; l-- {
+				sortLayer(layers[l], succ)
+			}
+		}
+		if c := layerCrossings(layers, pos, succ); c < bestCrossings {
+			best = copyLayers()
+			bestCrossings = c
+		}
+	}
+	return best
+}
+
+// places the layers from left to right and the nodes of each layer close to
+// the average position of their neighbours
+func (g *layoutGraph) placeNodes(layers [][]int) {
+	adj := make([][]int, len(g.Nodes))
+	for _, edge := range g.Edges {
+		for i := 0; i+1 < len(edge.route); i++ {
+			v, w := edge.route[i], edge.route[i+1]
+			adj[v] = append(adj[v], w)
+			adj[w] = append(adj[w], v)
+		}
+	}
+	x := LAYOUTMARGIN
+	for _, layer := range layers {
+		w := 0.0
+		for _, v := range layer {
+			w = math.Max(w, g.Nodes[v].w)
+		}
+		for _, v := range layer {
+			g.Nodes[v].x = x + w/2
+		}
+		x += w + LAYOUTRANKSEP
+	}
+	g.width = x - LAYOUTRANKSEP + LAYOUTMARGIN
+
+	gap := func(a, b int) float64 {
+		return (g.Nodes[a].h+g.Nodes[b].h)/2 + LAYOUTNODESEP
+	}
+	// places the nodes of the layer as close as possible to the desired
+	// positions, averaging a top-down and a bottom-up placement
+	place := func(layer []int, desired []float64) {
+		n := len(layer)
+		down := make([]float64, n)
+		up := make([]float64, n)
+		for i := 0; i < n; i++ {
+			down[i] = desired[i]
+			if i > 0 {
+				down[i] = math.Max(desired[i], down[i-1]+gap(layer[i-1],
+					layer[i]))
+			}
+		}
+		for i := n - 1; i >= 0; i-- {
+			up[i] = desired[i]
+			if i < n-1 {
+				up[i] = math.Min(desired[i], up[i+1]-gap(layer[i],
+					layer[i+1]))
+			}
+		}
+		for i, v := range layer {
+			g.Nodes[v].y = (down[i] + up[i]) / 2
+		}
+	}
+	for _, layer := range layers {
+		desired := make([]float64, len(layer))
+		place(layer, desired)
+	}
+	for pass := 0; pass < LAYOUTPASSES; pass++ {
+		for _, layer := range layers {
+			desired := make([]float64, len(layer))
+			for i, v := range layer {
+				desired[i] = g.Nodes[v].y
+				if len(adj[v]) > 0 {
+					sum := 0.0
+					for _, w := range adj[v] {
+						sum += g.Nodes[w].y
+					}
+					desired[i] = sum / float64(len(adj[v]))
+				}
+			}
+			place(layer, desired)
+		}
+	}
+
+	top, bottom := math.Inf(1), math.Inf(-1)
+	for _, node := range g.Nodes {
+		top = math.Min(top, node.y-node.h/2)
+		bottom = math.Max(bottom, node.y+node.h/2)
+	}
+	if len(g.Nodes) == 0 {
+		top, bottom = 0, 0
+	}
+	for v, _ := range g.Nodes {
+		g.Nodes[v].y += LAYOUTMARGIN - top
+	}
+	g.height = bottom - top + 2*LAYOUTMARGIN
+}
+
+// returns the point on the border of the node in the direction of p
+func (node *layoutNode) clip(p [2]float64) [2]float64 {
+	dx, dy := p[0]-node.x, p[1]-node.y
+	if node.dummy || (dx == 0 && dy == 0) {
+		return [2]float64{node.x, node.y}
+	}
+	t := 0.0
+	if node.Shape == SHAPECIRCLE {
+		t = node.w / 2 / math.Hypot(dx, dy)
+	} else {
+		t = math.Inf(1)
+		if dx != 0 {
+			t = node.w / 2 / math.Abs(dx)
+		}
+		if dy != 0 {
+			t = math.Min(t, node.h/2/math.Abs(dy))
+		}
+	}
+	return [2]float64{node.x + t*dx, node.y + t*dy}
+}
+
+// computes the positions of the nodes and the points of the edges
+func (g *layoutGraph) Layout() {
+	for v, _ := range g.Nodes {
+		g.Nodes[v].setSize()
+	}
+	g.removeCycles()
+	g.assignLayers()
+	g.addDummies()
+	g.placeNodes(g.orderLayers())
+	for ei, _ := range g.Edges {
+		edge := &g.Edges[ei]
+		if edge.Source == edge.Target {
+			continue
+		}
+		var points [][2]float64
+		for _, v := range edge.route {
+			points = append(points, [2]float64{g.Nodes[v].x, g.Nodes[v].y})
+		}
+		if edge.reversed {
+			for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+				points[i], points[j] = points[j], points[i]
+			}
+		}
+		n := len(points)
+		points[0] = g.Nodes[edge.Source].clip(points[1])
+		points[n-1] = g.Nodes[edge.Target].clip(points[n-2])
+		edge.points = points
+	}
+}
+
+// graphviz colours which are not SVG colours
+var x11Colors = map[string]string{
+	"darkgoldenrod1": "#ffb90f",
+	"firebrick1":     "#ff3030",
+	"grey27":         "#454545",
+	"slategray1":     "#c6e2ff",
+}
+
+// converts a graphviz colour (a name or "H S V") to an SVG colour
+func svgColor(color string) string {
+	if c, ok := x11Colors[color]; ok {
+		return c
+	}
+	hsv := strings.Fields(color)
+	if len(hsv) != 3 {
+		return color
+	}
+	var f [3]float64
+	for i, s := range hsv {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return color
+		}
+		f[i] = v
+	}
+	h, s, v := math.Mod(f[0], 1)*6, f[1], f[2]
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, gr, b float64
+	switch int(h) {
+	case 0:
+		r, gr = c, x
+	case 1:
+		r, gr = x, c
+	case 2:
+		gr, b = c, x
+	case 3:
+		gr, b = x, c
+	case 4:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := v - c
+	return fmt.Sprintf("#%02x%02x%02x", int(math.Round((r+m)*255)),
+		int(math.Round((gr+m)*255)), int(math.Round((b+m)*255)))
+}
+
+func svgPoints(points [][2]float64) string {
+	var ret []string
+	for _, p := range points {
+		ret = append(ret, fmt.Sprintf("%.1f,%.1f", p[0], p[1]))
+	}
+	return strings.Join(ret, " ")
+}
+
+// lays out the graph and returns it in SVG
+func (g *layoutGraph) SVG() string {
+	g.Layout()
+	var sb strings.Builder
+	sb.WriteString(SVGHEADER)
+	fmt.Fprintf(&sb, "<svg xmlns=\"http://www.w3.org/2000/svg\""+
+		" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\""+
+		" font-family=\"Courier New, Courier, monospace\""+
+		" font-weight=\"bold\" font-size=\"%.0f\">\n", g.width, g.height,
+		g.width, g.height, LAYOUTFONTSIZE)
+
+	// arrow heads, one per colour
+	markers := make(map[string]int)
+	var colors []string
+	for _, edge := range g.Edges {
+		if _, ok := markers[edge.Color]; edge.Arrow && !ok {
+			markers[edge.Color] = len(colors)
+			colors = append(colors, edge.Color)
+		}
+	}
+	sb.WriteString("<defs>\n")
+	for i, color := range colors {
+		fmt.Fprintf(&sb, "  <marker id=\"%sarrow%d\" viewBox=\"0 0 10 10\""+
+			" refX=\"10\" refY=\"5\" markerWidth=\"5\" markerHeight=\"5\""+
+			" orient=\"auto\"><path d=\"M0,0 L10,5 L0,10 z\""+
+			" fill=\"%s\"/></marker>\n", g.Prefix, i, svgColor(color))
+	}
+	sb.WriteString("</defs>\n")
+
+	for _, edge := range g.Edges {
+		attrs := fmt.Sprintf("fill=\"none\" stroke=\"%s\" stroke-width=\"2\"",
+			svgColor(edge.Color))
+		if edge.Dashed {
+			attrs += " stroke-dasharray=\"6,4\""
+		}
+		if edge.Arrow {
+			attrs += fmt.Sprintf(" marker-end=\"url(#%sarrow%d)\"",
+				g.Prefix, markers[edge.Color])
+		}
+		var lx, ly float64
+		if edge.Source == edge.Target {
+			node := &g.Nodes[edge.Source]
+			top := node.y - node.h/2
+			fmt.Fprintf(&sb, "<path d=\"M%.1f,%.1f C%.1f,%.1f %.1f,%.1f"+
+				" %.1f,%.1f\" %s/>\n", node.x-8, top, node.x-20, top-30,
+				node.x+20, top-30, node.x+8, top, attrs)
+			lx, ly = node.x, top-26
+		} else {
+			fmt.Fprintf(&sb, "<polyline points=\"%s\" %s/>\n",
+				svgPoints(edge.points), attrs)
+			i := (len(edge.points) - 1) / 2
+			lx = (edge.points[i][0] + edge.points[i+1][0]) / 2
+			ly = (edge.points[i][1]+edge.points[i+1][1])/2 - 4
+		}
+		if edge.Label != "" {
+			fmt.Fprintf(&sb, "<text x=\"%.1f\" y=\"%.1f\""+
+				" text-anchor=\"middle\" fill=\"%s\">%s</text>\n", lx, ly,
+				svgColor(edge.Color), html.EscapeString(edge.Label))
+		}
+	}
+
+	for _, node := range g.Nodes {
+		if node.dummy {
+			continue
+		}
+		attrs := fmt.Sprintf("fill=\"%s\" stroke=\"black\""+
+			" stroke-width=\"1\"", svgColor(node.Fill))
+		if node.Dashed {
+			attrs += " stroke-dasharray=\"4,3\""
+		}
+		fmt.Fprintf(&sb, "<g id=\"%s\">\n",
+			html.EscapeString(g.Prefix+node.ID))
+		switch node.Shape {
+		case SHAPECIRCLE:
+			fmt.Fprintf(&sb, "  <circle cx=\"%.1f\" cy=\"%.1f\" r=\"%.1f\""+
+				" %s/>\n", node.x, node.y, node.w/2, attrs)
+		default:
+			rx := 0.0
+			if node.Shape == SHAPEROUNDED {
+				rx = 8
+			}
+			fmt.Fprintf(&sb, "  <rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\""+
+				" height=\"%.1f\" rx=\"%.1f\" %s/>\n", node.x-node.w/2,
+				node.y-node.h/2, node.w, node.h, rx, attrs)
+		}
+		y := node.y - float64(len(node.Lines)-1)*LAYOUTLINEHEIGHT/2 +
+			LAYOUTFONTSIZE/3
+		for i, line := range node.Lines {
+			fmt.Fprintf(&sb, "  <text x=\"%.1f\" y=\"%.1f\""+
+				" text-anchor=\"middle\">%s</text>\n", node.x,
+				y+float64(i)*LAYOUTLINEHEIGHT, html.EscapeString(line))
+		}
+		sb.WriteString("</g>\n")
+	}
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
+
+func dotEscape(s string) string {
+	return strings.ReplaceAll(s, "\"", "\\\"")
+}
+
+// returns the graph in DOT
+func (g *layoutGraph) DOT() string {
+	ret := "digraph g {\n"
+	ret += "  rankdir=\"LR\";\n" // horizontal layout
+	for _, node := range g.Nodes {
+		if node.dummy {
+			continue
+		}
+		var lines []string
+		for _, line := range node.Lines {
+			lines = append(lines, dotEscape(line))
+		}
+		shape, style := node.Shape, "filled,solid"
+		if node.Dashed {
+			style = "filled,dashed"
+		}
+		if shape == SHAPEROUNDED {
+			shape, style = SHAPEBOX, style+",rounded"
+		}
+		ret += fmt.Sprintf("  %s [label=\"%s\", shape=%s"+
+			", style=\"%s\", fillcolor=\"%s\""+
+			", fontname=\"Courier-Bold\"];\n", node.ID,
+			strings.Join(lines, "\\n"), shape, style, node.Fill)
+	}
+	for _, edge := range g.Edges {
+		attrs := fmt.Sprintf("penwidth=2, color=\"%s\", fontcolor=\"%s\"",
+			edge.Color, edge.Color)
+		if edge.Label != "" {
+			attrs = fmt.Sprintf("label=\"%s\", ", dotEscape(edge.Label)) +
+				attrs
+		}
+		if edge.Dashed {
+			attrs += ", style=dashed"
+		}
+		if !edge.Arrow {
+			attrs += ", arrowhead=none"
+		}
+		ret += fmt.Sprintf("  %s -> %s [%s];\n", g.Nodes[edge.Source].ID,
+			g.Nodes[edge.Target].ID, attrs)
+	}
+	ret += "}\n"
+	return ret
+}
+
+// returns the net as a graph, with the labels and colours of PrintDOT
+func (pn *PNML) layoutGraph() *layoutGraph {
+	g := newLayoutGraph()
+	for _, place := range pn.Net.Page.Places {
+		g.addNode(layoutNode{ID: place.ID, Lines: []string{place.ID},
+			Shape: SHAPECIRCLE, Fill: dotTypeColor(place.Type, "")})
+	}
+	for _, trans := range pn.Net.Page.Transitions {
+		label := trans.OrigName
+		if label == "" {
+			label = trans.Name
+		}
+		if trans.Type == TAU {
+			label = TAUSYM
+		}
+		g.addNode(layoutNode{ID: trans.ID, Lines: []string{label},
+			Shape: SHAPEBOX, Fill: dotTypeColor(trans.Type, trans.Selected)})
+	}
+	for _, arc := range pn.Net.Page.Arcs {
+		g.addEdge(arc.Source, arc.Target, layoutEdge{
+			Color: pn.dotArcColor(&arc), Arrow: true})
+	}
+	return g
+}
+
+func (pn *PNML) PrintSVG(filename string) {
+	WriteFile(filename, pn.layoutGraph().SVG())
+}
+
+// writes the drawings of the net as basename.dot and basename.svg
+func (pn *PNML) writeDrawings(basename string, drawings []string) {
+	for _, drawing := range drawings {
+		switch drawing {
+		case DRAWDOT:
+			pn.PrintDOT(basename + ".dot")
+		case DRAWSVG:
+			pn.PrintSVG(basename + ".svg")
+		}
+	}
+}
+
+// returns the marking graph as a graph, with the labels and colours of
+// PrintDOT, final markings are dashed
+func (mg *MarkingGraph) layoutGraph() *layoutGraph {
+	g := newLayoutGraph()
+	for _, marking := range mg.Markings {
+		g.addNode(layoutNode{ID: fmt.Sprintf("m%d", marking.ID),
+			Lines: []string{mg.Multiset(marking)}, Shape: SHAPEROUNDED,
+			Fill: mg.dotFillColor(marking), Dashed: mg.isFinal(marking.ID)})
+	}
+	for _, edge := range mg.Edges {
+		g.addEdge(fmt.Sprintf("m%d", edge.Source),
+			fmt.Sprintf("m%d", edge.Target), layoutEdge{Label: edge.Label,
+				Color: edge.dotColor(), Arrow: true})
+	}
+	return g
+}
+
+func (mg *MarkingGraph) PrintSVG(filename string) {
+	WriteFile(filename, mg.layoutGraph().SVG())
+}
+
+// draws the net, or its marking graph, as DOT or SVG by the extension of
+// outfn
+func DrawNet(netfn, outfn string, markingGraph bool) {
+	if markingGraph {
+		ExportMarkingGraph(netfn, outfn, false)
+		return
+	}
+	pn := readNet(netfn)
+	if strings.HasSuffix(outfn, ".svg") {
+		pn.PrintSVG(outfn)
+	} else {
+		pn.PrintDOT(outfn)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Selection of the log traces before constructing the products: filters on
+// the length, activities, trace attributes and time of the traces, then on
+// the frequency of their variants (sequences of activities) among the
+// remaining traces, and finally a random sample. The selected traces keep
+// their order in the log and are numbered from 0, the manifest maps these
+// indices to the traces of the log.
+
+type LogTrace struct {
+	ID         string // concept:name of the trace, or its index
+	Index      int    // index in the log
+	Events     []string
+	Attributes map[string]string // trace attributes of XES logs
+	Start      time.Time         // time of the first event, zero if unknown
+	End        time.Time         // time of the last event, zero if unknown
+}
+
+type FilterOptions struct {
+	MinLength   int               // minimal number of events
+	MaxLength   int               // maximal number of events, 0 for all
+	Contains    []string          // activities that all should occur
+	Excludes    []string          // activities that should not occur
+	Attributes  map[string]string // trace attribute values
+	From        time.Time         // traces start at or after From
+	To          time.Time         // traces end before To, zero for all
+	MinVariant  int               // minimal number of traces of the variant
+	TopVariants int               // most frequent variants, 0 for all
+	Sample      int               // random sample of traces, 0 for all
+	Seed        int64
+}
+
+// layouts of the times of -from and -to and of XES timestamps
+var FILTERTIMELAYOUTS = []string{time.RFC3339, "2006-01-02T15:04:05",
+	"2006-01-02"}
+
+// parses a time as RFC 3339, or a date or time without a time zone in UTC
+func ParseFilterTime(value string) (time.Time, error) {
+	for _, layout := range FILTERTIMELAYOUTS {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("Unknown time '" + value +
+		"', expected YYYY-MM-DD or RFC 3339")
+}
+
+func (opts *FilterOptions) Check() error {
+	if opts.MinLength < 0 || opts.MaxLength < 0 || opts.MinVariant < 0 ||
+		opts.TopVariants < 0 || opts.Sample < 0 {
+		return errors.New("Negative trace filter bound")
+	}
+	if opts.MaxLength > 0 && opts.MaxLength < opts.MinLength {
+		return fmt.Errorf("Empty trace length range %d-%d", opts.MinLength,
+			opts.MaxLength)
+	}
+	if !opts.To.IsZero() && !opts.From.Before(opts.To) {
+		return errors.New("Empty time window")
+	}
+	return nil
+}
+
+// returns the traces of the CSV or XES log, the traces of CSV logs have no
+// attributes and times
+func ReadLogTraces(logfn string) []LogTrace {
+	if strings.HasSuffix(logfn, ".xes") {
+		return ParseXESTraces(logfn)
+	}
+	var ret []LogTrace
+	for i, events := range readLog(logfn) {
+		ret = append(ret, LogTrace{ID: fmt.Sprint(i), Index: i,
+			Events: events})
+	}
+	return ret
+}
+
+func (t *LogTrace) variant() string {
+	return strings.Join(t.Events, ",")
+}
+
+// returns whether the trace passes the filters on single traces
+func (opts *FilterOptions) accepts(t *LogTrace) bool {
+	if len(t.Events) < opts.MinLength ||
+		(opts.MaxLength > 0 && len(t.Events) > opts.MaxLength) {
+		return false
+	}
+	occurs := make(map[string]bool)
+	for _, event := range t.Events {
+		occurs[event] = true
+	}
+	for _, activity := range opts.Contains {
+		if !occurs[activity] {
+			return false
+		}
+	}
+	for _, activity := range opts.Excludes {
+		if occurs[activity] {
+			return false
+		}
+	}
+	for key, value := range opts.Attributes {
+		if v, ok := t.Attributes[key]; !ok || v != value {
+			return false
+		}
+	}
+	if !opts.From.IsZero() &&
+		(t.Start.IsZero() || t.Start.Before(opts.From)) {
+		return false
+	}
+	if !opts.To.IsZero() && (t.End.IsZero() || !t.End.Before(opts.To)) {
+		return false
+	}
+	return true
+}
+
+// returns the traces of the variants that occur at least MinVariant times
+// and are among the TopVariants most frequent ones, ties are broken by the
+// first occurrence
+func (opts *FilterOptions) filterVariants(traces []LogTrace) []LogTrace {
+	if opts.MinVariant <= 1 && opts.TopVariants == 0 {
+		return traces
+	}
+	count := make(map[string]int)
+	var variants []string // in order of first occurrence
+	for i := range traces {
+		v := traces[i].variant()
+		if count[v] == 0 {
+			variants = append(variants, v)
+		}
+		count[v] += 1
+	}
+	sort.SliceStable(variants, func(i, j int) bool {
+		return count[variants[i]] > count[variants[j]]
+	})
+	if opts.TopVariants > 0 && len(variants) > opts.TopVariants {
+		variants = variants[:opts.TopVariants]
+	}
+	selected := make(map[string]bool)
+	for _, v := range variants {
+		if count[v] >= opts.MinVariant {
+			selected[v] = true
+		}
+	}
+	var ret []LogTrace
+	for _, t := range traces {
+		if selected[t.variant()] {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+// returns whether any of the filters is set
+func (opts *FilterOptions) Active() bool {
+	return opts.MinLength > 0 || opts.MaxLength > 0 ||
+		len(opts.Contains) > 0 || len(opts.Excludes) > 0 ||
+		len(opts.Attributes) > 0 || !opts.From.IsZero() ||
+		!opts.To.IsZero() || opts.MinVariant > 0 || opts.TopVariants > 0 ||
+		opts.Sample > 0
+}
+
+// returns the selected traces in the order of the log
+func (opts *FilterOptions) Filter(traces []LogTrace) []LogTrace {
+	var ret []LogTrace
+	for i := range traces {
+		if opts.accepts(&traces[i]) {
+			ret = append(ret, traces[i])
+		}
+	}
+	ret = opts.filterVariants(ret)
+	if opts.Sample > 0 && len(ret) > opts.Sample {
+		perm := rand.New(rand.NewSource(opts.Seed)).Perm(len(ret))
+		sample := perm[:opts.Sample]
+		sort.Ints(sample)
+		var sampled []LogTrace
+		for _, i := range sample {
+			sampled = append(sampled, ret[i])
+		}
+		ret = sampled
+	}
+	return ret
+}
+
+// returns the manifest of the selected traces as CSV: the index of the
+// product, the index of the trace in the log, its ID and number of events
+func Manifest(traces []LogTrace) string {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write([]string{"index", "trace", "id", "events"})
+	for i, t := range traces {
+		w.Write([]string{fmt.Sprint(i), fmt.Sprint(t.Index), t.ID,
+			fmt.Sprint(len(t.Events))})
+	}
+	w.Flush()
+	return sb.String()
+}
+
+// reads the log, selects the traces and writes the manifest to outdir when
+// a filter is set
+func readFilteredLog(logfn, outdir string, opts FilterOptions) [][]string {
+	CheckError(opts.Check())
+	traces := ReadLogTraces(logfn)
+	if !opts.From.IsZero() || !opts.To.IsZero() {
+		untimed := 0
+		for _, t := range traces {
+			if t.Start.IsZero() {
+				untimed += 1
+			}
+		}
+		if untimed > 0 {
+			fmt.Printf("Warning: %d traces without a timestamp are not"+
+				" selected by -from and -to\n", untimed)
+		}
+	}
+	traces = opts.Filter(traces)
+	if opts.Active() {
+		WriteFile(filepath.Join(outdir, "manifest.csv"), Manifest(traces))
+	}
+	var ret [][]string
+	for _, t := range traces {
+		ret = append(ret, t.Events)
+	}
+	return ret
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// The traces of testdata/filter.xes:
+//
+//	c1 org A  a,b    2024-01-05
+//	c2 org B  a,x,b  2024-02-10
+//	c3 org A  a,b    2024-02-15
+//	c4 org B  b      2024-03-01
+//	c5 org A  a,b    2024-02-28 - 2024-03-01
+
+func filterIDs(traces []LogTrace) []string {
+	ids := []string{}
+	for _, t := range traces {
+		ids = append(ids, t.ID)
+	}
+	return ids
+}
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	ret, err := ParseFilterTime(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ret
+}
+
+func TestParseXESTraces(t *testing.T) {
+	traces := ParseXESTraces("testdata/filter.xes")
+	if len(traces) != 5 {
+		t.Fatalf("expected 5 traces, got %d", len(traces))
+	}
+	// XES timestamps without a time zone are in UTC
+	if c4 := traces[3]; !c4.Start.Equal(mustParseTime(t,
+		"2024-03-01T12:00:00Z")) {
+		t.Errorf("unexpected start %v of c4", c4.Start)
+	}
+	c5 := traces[4]
+	if c5.ID != "c5" || c5.Index != 4 || c5.Attributes["org:group"] != "A" ||
+		!reflect.DeepEqual(c5.Events, []string{"a", "b"}) {
+		t.Errorf("unexpected trace %v", c5)
+	}
+	if !c5.Start.Equal(mustParseTime(t, "2024-02-28T23:00:00Z")) ||
+		!c5.End.Equal(mustParseTime(t, "2024-03-01T01:00:00Z")) {
+		t.Errorf("unexpected times %v - %v", c5.Start, c5.End)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	traces := ParseXESTraces("testdata/filter.xes")
+	cases := []struct {
+		name string
+		opts FilterOptions
+		ids  []string
+	}{
+		{"none", FilterOptions{}, []string{"c1", "c2", "c3", "c4", "c5"}},
+		{"min-length", FilterOptions{MinLength: 2},
+			[]string{"c1", "c2", "c3", "c5"}},
+		{"max-length", FilterOptions{MaxLength: 2},
+			[]string{"c1", "c3", "c4", "c5"}},
+		{"contains", FilterOptions{Contains: []string{"a", "x"}},
+			[]string{"c2"}},
+		{"excludes", FilterOptions{Excludes: []string{"x"}},
+			[]string{"c1", "c3", "c4", "c5"}},
+		{"attr", FilterOptions{Attributes: map[string]string{
+			"org:group": "A"}}, []string{"c1", "c3", "c5"}},
+		{"unknown attr", FilterOptions{Attributes: map[string]string{
+			"org:role": "A"}}, []string{}},
+		{"from", FilterOptions{From: mustParseTime(t, "2024-02-01")},
+			[]string{"c2", "c3", "c4", "c5"}},
+		{"window", FilterOptions{From: mustParseTime(t, "2024-02-01"),
+			To: mustParseTime(t, "2024-03-01")}, []string{"c2", "c3"}},
+		{"min-variant", FilterOptions{MinVariant: 2},
+			[]string{"c1", "c3", "c5"}},
+		{"top-variants", FilterOptions{TopVariants: 2},
+			[]string{"c1", "c2", "c3", "c5"}},
+		{"attr top-variants", FilterOptions{TopVariants: 1,
+			Attributes: map[string]string{"org:group": "B"}},
+			[]string{"c2"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.opts.Check(); err != nil {
+				t.Fatal(err)
+			}
+			if ids := filterIDs(c.opts.Filter(traces)); !reflect.DeepEqual(
+				ids, c.ids) {
+				t.Errorf("expected %v, got %v", c.ids, ids)
+			}
+		})
+	}
+}
+
+func TestFilterSample(t *testing.T) {
+	traces := ParseXESTraces("testdata/filter.xes")
+	opts := FilterOptions{Sample: 3, Seed: 42}
+	first := opts.Filter(traces)
+	if len(first) != 3 {
+		t.Fatalf("expected 3 traces, got %v", filterIDs(first))
+	}
+	for i := 1; i < len(first); i++ {
+		if first[i-1].Index >= first[i].Index {
+			t.Errorf("sample not in the order of the log: %v",
+				filterIDs(first))
+		}
+	}
+	if second := opts.Filter(traces); !reflect.DeepEqual(first, second) {
+		t.Errorf("samples with the same seed differ: %v and %v",
+			filterIDs(first), filterIDs(second))
+	}
+}
+
+func TestFilterCheck(t *testing.T) {
+	for _, opts := range []FilterOptions{{MinLength: 3, MaxLength: 2},
+		{Sample: -1}, {From: mustParseTime(t, "2024-03-01"),
+			To: mustParseTime(t, "2024-02-01")}} {
+		if opts.Check() == nil {
+			t.Errorf("expected an error for %v", opts)
+		}
+	}
+}
+
+func TestCreatePNMLProductFiltered(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultProductOptions
+	opts.Drawings = nil
+	opts.Filter.Attributes = map[string]string{"org:group": "B"}
+	CreatePNMLProduct("testdata/small.pnml", "testdata/filter.xes", dir,
+		opts)
+	manifest, err := os.ReadFile(filepath.Join(dir, "manifest.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "index,trace,id,events\n0,1,c2,3\n1,3,c4,1\n"
+	if string(manifest) != expected {
+		t.Errorf("unexpected manifest:\n%s", manifest)
+	}
+	indices, err := syncModelIndices(dir, DEFAULTPRODUCTNAME)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(indices, []int{0, 1}) {
+		t.Errorf("expected products 0 and 1, got %v", indices)
+	}
+	var pn PNML
+	err = xml.Unmarshal(readPNML(filepath.Join(dir, "syncmodel-1.pnml")), &pn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPlaces, _ := countType(&pn, LOG)
+	if logPlaces != 2 { // product of trace c4 with a single event
+		t.Errorf("expected 2 log places in product 1, got %d", logPlaces)
+	}
+}
+
+// without trace filters the products are numbered as the log traces
+func TestCreatePNMLProductNoManifest(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultProductOptions
+	opts.Drawings = nil
+	CreatePNMLProduct("testdata/small.pnml", "testdata/filter.xes", dir,
+		opts)
+	if _, err := os.Stat(filepath.Join(dir, "manifest.csv")); err == nil {
+		t.Errorf("expected no manifest without trace filters")
+	}
+}
@@ -4,34 +4,582 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"regexp"
+	"runtime/debug"
+	"strings"
 )
 
+// The command line consists of subcommands with their own flags, e.g.
+// 'pnmlprod product MODEL LOG OUTDIR -prop ctl'. Flags may follow the
+// arguments. The options of the former command line (-p, -a, ...) are
+// accepted as aliases of the subcommands.
+
+// set at build time with -ldflags "-X main.version=VERSION"
+var version = ""
+
+// returns the version of the build, or of the module if not set
+func Version() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+type command struct {
+	Name    string
+	Alias   string // option of the former command line
+	Args    string // usage of the arguments
+	MinArgs int
+	MaxArgs int // -1 for unbounded
+	Summary string
+	Doc     string
+	// defines the flags of the command and returns its action, which is
+	// called with the arguments after parsing the flags
+	Setup func(fs *flag.FlagSet) func(args []string)
+}
+
+var commands = []command{
+	{Name: "product", Alias: "-p", Args: "MODEL.{pnml,bpmn,ptml}" +
+		"  LOGFILE.{csv,xes}  OUTPUTDIR", MinArgs: 3, MaxArgs: 3,
+		Summary: "construct the synchronous products of the log traces",
+		Doc: "Constructs a synchronous product for each log trace x in" +
+			" LOGFILE, to be used\nin pnml2lts-sym for computing an" +
+			" alignment trace. The product is written as\n" +
+			"'syncmodel-x.FORMAT' and its property as 'invariant-x.txt'," +
+			" or with -prop ctl\nor -prop ltl as 'property-x.ctl' or" +
+			" 'property-x.ltl'. The products are drawn\nas" +
+			" 'syncmodel-x.dot', the model is only drawn with -draw-model," +
+			" in OUTPUTDIR.\nThe silent transitions are reported in" +
+			" 'silent.txt', unmatched labels in\n'labels.txt' and the size" +
+			" of each product in 'diagnostics.csv'.\nWith the trace filters," +
+			" only the products of the selected traces are written,\n" +
+			"numbered from 0, and 'manifest.csv' maps them to the traces of" +
+			" the log.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			opts := addProductFlags(fs)
+			addFilterFlags(fs, &opts.Filter)
+			return func(args []string) {
+				CreatePNMLProduct(args[0], args[1], args[2], *opts)
+			}
+		}},
+	{Name: "diagnose", Alias: "-d", Args: "MODEL.{pnml,bpmn,ptml}" +
+		"  LOGFILE.{csv,xes}", MinArgs: 2, MaxArgs: 2,
+		Summary: "print the sizes of the products without constructing them",
+		Doc: "Prints the size of the synchronous product of each log trace," +
+			" including the\nnumber of sync transitions per event for" +
+			" duplicate labels, without\nconstructing the products.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			opts := addProductFlags(fs)
+			return func(args []string) {
+				DiagnoseProducts(args[0], args[1], *opts)
+			}
+		}},
+	{Name: "replay", Alias: "-r", Args: "MODEL.{pnml,bpmn,ptml}" +
+		"  LOGFILE.{csv,xes}", MinArgs: 2, MaxArgs: 2,
+		Summary: "token-based replay of the log on the model",
+		Doc: "Token-based replay of each log trace on the model. Prints the" +
+			" produced,\nconsumed, missing and remaining tokens and the" +
+			" fitness per trace and for the\nlog as CSV. Each event without a" +
+			" model transition counts as one missing and\none remaining" +
+			" token.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			opts := addProductFlags(fs)
+			return func(args []string) {
+				ReplayLog(args[0], args[1], *opts)
+			}
+		}},
+	{Name: "enumerate", Alias: "-e", Args: "MODEL.{pnml,bpmn,ptml}" +
+		"  LOGFILE.{csv,xes}", MinArgs: 2, MaxArgs: 2,
+		Summary: "compute the optimal alignments by a search of the products",
+		Doc: "Computes the optimal alignments of each log trace on its" +
+			" synchronous product,\nby a search of the product. The" +
+			" alignments are printed per trace, grouped by\ntheir multiset" +
+			" of model moves.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			opts := addProductFlags(fs)
+			limit := fs.Int("limit", 10, "print at most `K` alignments per"+
+				" trace, 0 for all")
+			return func(args []string) {
+				EnumerateAlignments(args[0], args[1], *opts, *limit)
+			}
+		}},
+	{Name: "online", Alias: "-online", Args: "MODEL.{pnml,bpmn,ptml}",
+		MinArgs: 1, MaxArgs: 1,
+		Summary: "prefix alignments of the events on the standard input",
+		Doc: "Reads events 'CASE,ACTIVITY' from the standard input and" +
+			" prints the cost of\nthe optimal prefix alignment of the case" +
+			" after each event. Events that\nincrease the cost are flagged" +
+			" as deviations. A case only ends with the line\n'CASE,', after" +
+			" which the cost of completing the case is printed and marked\nby" +
+			" ',end'.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			opts := addProductFlags(fs)
+			return func(args []string) {
+				OnlineAlignments(args[0], *opts)
+			}
+		}},
+	{Name: "precision", Alias: "-precision", Args: "MODEL.{pnml,bpmn,ptml}" +
+		"  LOGFILE.{csv,xes}", MinArgs: 2, MaxArgs: 2,
+		Summary: "alignment-based (ETC) precision of the model",
+		Doc: "Computes the alignment-based (ETC) precision of the model: the" +
+			" visible\ntransitions enabled after a prefix of the aligned" +
+			" model moves that are never\nobserved in the log are escaping" +
+			" edges.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			opts := addProductFlags(fs)
+			return func(args []string) {
+				PrintPrecision(args[0], args[1], *opts)
+			}
+		}},
+	{Name: "quality", Alias: "-quality", Args: "MODEL.{pnml,bpmn,ptml}" +
+		"  {LOGFILE.{csv,xes} | OUTPUTDIR}", MinArgs: 2, MaxArgs: 2,
+		Summary: "fitness, precision, generalization and simplicity",
+		Doc: "Computes the fitness, precision and generalization of the" +
+			" model from the\noptimal alignments of the log, and the" +
+			" simplicity measures of the net (size,\narc degree," +
+			" cyclomatic number, connector mismatch). Given the OUTPUTDIR" +
+			"\nof run, the alignments are reconstructed from the solver" +
+			" traces of its\nproducts instead.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			opts := addProductFlags(fs)
+			pattern := addTraceFlag(fs)
+			return func(args []string) {
+				PrintQuality(args[0], args[1], *pattern, *opts)
+			}
+		}},
+	{Name: "annotate", Alias: "-annotate", Args: "MODEL.{pnml,bpmn,ptml}" +
+		"  LOGFILE.{csv,xes}  OUTFILE.{dot,svg}", MinArgs: 3, MaxArgs: 3,
+		Summary: "draw the model with the moves of the alignments",
+		Doc: "Draws the model with the number of sync and model moves of" +
+			" each transition in\nthe optimal alignments of the log, and" +
+			" the log moves as dangling inserted\nactivities next to the" +
+			" marked places.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			opts := addProductFlags(fs)
+			return func(args []string) {
+				AnnotateModel(args[0], args[1], args[2], *opts)
+			}
+		}},
+	{Name: "html", Alias: "-html", Args: "MODEL.{pnml,bpmn,ptml}" +
+		"  LOGFILE.{csv,xes}  OUTFILE.html", MinArgs: 3, MaxArgs: 3,
+		Summary: "HTML report of the optimal alignments",
+		Doc: "Writes a self-contained HTML report with an index of the" +
+			" traces that can be\nsorted by cost, and per trace the" +
+			" optimal alignment as a log row and a model\nrow coloured by" +
+			" move type, and the drawing of the product with the\n" +
+			"transitions of the alignment selected.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			opts := addProductFlags(fs)
+			return func(args []string) {
+				WriteHTMLReport(args[0], args[1], args[2], *opts)
+			}
+		}},
+	{Name: "html-dir", Alias: "-html-dir", Args: "OUTPUTDIR  OUTFILE.html",
+		MinArgs: 2, MaxArgs: 2,
+		Summary: "HTML report of the alignments of the solver traces",
+		Doc: "Writes the HTML report of html with the alignments" +
+			" reconstructed from the\nsolver traces in OUTPUTDIR, as batch" +
+			" does.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			name, pattern := addBatchFlags(fs)
+			return func(args []string) {
+				WriteBatchHTMLReport(args[0], *name, *pattern, args[1])
+			}
+		}},
+	{Name: "serve", Alias: "-serve", MaxArgs: 0,
+		Summary: "HTTP interface for products and alignments",
+		Doc: "Serves an HTTP interface to upload models, logs and solver" +
+			" traces, construct\nproducts as product does, compute" +
+			" alignments natively or reconstruct them as\nbatch does, and" +
+			" fetch the results as JSON (see serve.go for the endpoints).",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			opts := DefaultServeOptions
+			fs.StringVar(&opts.Addr, "addr", opts.Addr, "listen on `ADDR`")
+			fs.Int64Var(&opts.MaxUpload, "max-upload", opts.MaxUpload,
+				"limit requests to `BYTES`")
+			fs.IntVar(&opts.Jobs, "jobs", opts.Jobs, "run `N` parallel"+
+				" workers")
+			fs.IntVar(&opts.Queue, "queue", opts.Queue, "queue at most `N`"+
+				" jobs")
+			fs.StringVar(&opts.Dir, "dir", opts.Dir, "store the files in `DIR`"+
+				" (default: a temporary directory)")
+			return func(args []string) {
+				CheckError(Serve(opts))
+			}
+		}},
+	{Name: "mg", Alias: "-mg", Args: "NET.{pnml,bpmn,ptml}" +
+		"  OUTFILE.{dot,svg,aut,json}", MinArgs: 2, MaxArgs: 2,
+		Summary: "write the marking graph of the net",
+		Doc: "Writes the marking graph of the net with each marking as a" +
+			" multiset of places,\nthe initial marking in green and the" +
+			" final markings in salmon, in DOT,\nSVG, Aldebaran or as a JSON" +
+			" adjacency list.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			collapse := fs.Bool("collapse-tau", false, "collapse TAU edges"+
+				" into the visible edges following them")
+			return func(args []string) {
+				ExportMarkingGraph(args[0], args[1], *collapse)
+			}
+		}},
+	{Name: "draw", Alias: "-svg", Args: "NET.{pnml,bpmn,ptml}" +
+		"  OUTFILE.{dot,svg}", MinArgs: 2, MaxArgs: 2,
+		Summary: "draw the net or its marking graph",
+		Doc: "Draws the net in DOT or in SVG with the built-in layered" +
+			" layout, which does\nnot require graphviz.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			mg := fs.Bool("mg", false, "draw the marking graph")
+			return func(args []string) {
+				DrawNet(args[0], args[1], *mg)
+			}
+		}},
+	{Name: "run", Alias: "-run", Args: "MODEL.{pnml,bpmn,ptml}" +
+		"  LOGFILE.{csv,xes}  OUTPUTDIR", MinArgs: 3, MaxArgs: 3,
+		Summary: "construct the products, run the checker and align",
+		Doc: "Constructs the synchronous product of each log trace as" +
+			" product does, runs\nthe model checker command on it and" +
+			" constructs the alignment from its trace.\nIn the command," +
+			" {model}, {property} and {trace} are replaced by the (quoted)" +
+			"\nproduct, property and trace file. A table of the results is" +
+			" printed, the\nalignments are written to 'alignments.txt' and" +
+			" the results to 'results.csv'.\nThe traces are selected by the" +
+			" trace filters as product does.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			opts := addProductFlags(fs)
+			addFilterFlags(fs, &opts.Filter)
+			ropts := DefaultRunOptions
+			fs.StringVar(&ropts.Command, "cmd", ropts.Command, "model"+
+				" checker `COMMAND`")
+			fs.StringVar(&ropts.TraceExt, "trace-ext", ropts.TraceExt,
+				"extension `EXT` of the trace written by the checker")
+			fs.DurationVar(&ropts.Timeout, "timeout", ropts.Timeout,
+				"timeout per trace")
+			fs.IntVar(&ropts.Jobs, "jobs", ropts.Jobs, "run `N` checkers in"+
+				" parallel")
+			return func(args []string) {
+				RunAlignments(args[0], args[1], args[2], *opts, ropts)
+			}
+		}},
+	{Name: "align", Alias: "-a", Args: "SYNCMODEL.pnml" +
+		"  TRACE.{txt,csv,gcf,dir,json}", MinArgs: 2, MaxArgs: 2,
+		Summary: "construct the alignment of a solver trace",
+		Doc: "Constructs an alignment from the synchronous product and the" +
+			" trace of the\nsolver, and prints it on the standard output." +
+			" The trace is the text or CSV\noutput of ltsmin-printtrace, an" +
+			" LTSmin trace archive (converted with\nltsmin-printtrace) or a" +
+			" JSON trace.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			format := addOutputFlag(fs)
+			return func(args []string) {
+				checkOutputFormat(fs, *format)
+				TraceToAlign(args[0], args[1], *format)
+			}
+		}},
+	{Name: "batch", Alias: "-b", Args: "OUTPUTDIR", MinArgs: 1, MaxArgs: 1,
+		Summary: "construct the alignments of all products in a directory",
+		Doc: "Constructs the alignments for all products in OUTPUTDIR from" +
+			" their solver\ntraces. The alignments are written to" +
+			" 'alignments.{txt,json}' and a summary of\nmissing and failed" +
+			" traces to 'summary.txt'.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			name, pattern := addBatchFlags(fs)
+			format := addOutputFlag(fs)
+			return func(args []string) {
+				checkOutputFormat(fs, *format)
+				BatchTraceToAlign(args[0], *name, *pattern, *format)
+			}
+		}},
+	{Name: "validate", Alias: "-v", Args: "MODEL.{pnml,bpmn,ptml}" +
+		"  LOGFILE.{csv,xes}  INDEX  ALIGNMENT.{txt,json}", MinArgs: 4,
+		MaxArgs: 4,
+		Summary: "validate an alignment of a log trace",
+		Doc: "Validates the alignment of log trace INDEX (starting at 0), as" +
+			" printed by\nalign: the log moves should form the log trace" +
+			" and the model moves should be\na firing sequence of the model" +
+			" to the final marking. Prints the cost of a\nvalid alignment.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			opts := addProductFlags(fs)
+			return func(args []string) {
+				ValidateAlignmentFile(args[0], args[1], args[2], args[3],
+					*opts)
+			}
+		}},
+	{Name: "check", Alias: "-c", Args: "MODEL.pnml", MinArgs: 1, MaxArgs: 1,
+		Summary: "print the size of the net",
+		Doc: "Returns the size of the Petri net model; the number of" +
+			" places, transitions\nand arcs.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			return func(args []string) {
+				CheckModel(args[0])
+			}
+		}},
+	{Name: "invariants", Alias: "-i", Args: "MODEL.pnml  [INVARIANT.txt]",
+		MinArgs: 1, MaxArgs: 2,
+		Summary: "compute the P- and T-semiflows of the net",
+		Doc: "Computes the P-semiflows and T-semiflows of the Petri net" +
+			" model and reports\nwhether it is covered by P-invariants. If" +
+			" INVARIANT.txt is given, the\nP-invariants are written to it" +
+			" in LTSmin syntax.",
+		Setup: func(fs *flag.FlagSet) func(args []string) {
+			return func(args []string) {
+				invariantfn := ""
+				if len(args) == 2 {
+					invariantfn = args[1]
+				}
+				CheckInvariants(args[0], invariantfn)
+			}
+		}},
+}
+
+// comma-separated list of values, an empty value for the empty list
+type listFlag struct {
+	list *[]string
+}
+
+func (f listFlag) String() string {
+	if f.list == nil {
+		return ""
+	}
+	return strings.Join(*f.list, ",")
+}
+
+func (f listFlag) Set(value string) error {
+	*f.list = nil
+	if value != "" {
+		*f.list = strings.Split(value, ",")
+	}
+	return nil
+}
+
+// file name pattern, see CheckNamePattern
+type nameFlag struct {
+	name *string
+}
+
+func (f nameFlag) String() string {
+	if f.name == nil {
+		return ""
+	}
+	return *f.name
+}
+
+func (f nameFlag) Set(value string) error {
+	*f.name = value
+	return CheckNamePattern(value)
+}
+
+// defines the flags of the product options
+func addProductFlags(fs *flag.FlagSet) *ProductOptions {
+	opts := DefaultProductOptions
+	// NB: the defaults are shared, -tau-list appends to Detect
+	opts.Silent.Detect = append([]string{}, opts.Silent.Detect...)
+	fs.StringVar(&opts.Property.Format, "prop", opts.Property.Format,
+		"property `FORMAT` of the products: ltsmin, ctl or ltl")
+	fs.IntVar(&opts.Property.CostBound, "k", opts.Property.CostBound,
+		"the final marking can only be reached with at most `BOUND` log"+
+			" and model\nmoves, -1 for unbounded")
+	fs.Var(listFlag{&opts.Formats}, "format", "comma-separated output"+
+		" `FORMATS` of the products: pnml, lola, net,\nndr, tpn")
+	fs.Var(listFlag{&opts.Drawings}, "draw", "comma-separated `FORMATS` of"+
+		" the drawings: dot or svg, an empty\nvalue for none")
+	fs.BoolVar(&opts.DrawModel, "draw-model", false, "also draw the model in"+
+		" OUTPUTDIR, in the formats of -draw")
+	fs.Var(nameFlag{&opts.Name}, "name", "file name `PATTERN` of the"+
+		" products without extension, %d is\nthe index of the product")
+	fs.Var(nameFlag{&opts.Property.Name}, "property-name", "file name"+
+		" `PATTERN` of the properties without extension (default\n"+
+		"invariant-%d or property-%d)")
+	fs.Var(listFlag{&opts.Silent.Detect}, "tau-detect", "comma-separated"+
+		" `METHODS` of detecting silent transitions:\nprom (ProM's"+
+		" invisible marker), empty (label), regex, list")
+	fs.Func("tau-regex", "silent transitions have a label matching `REGEX`"+
+		" (default\n'"+opts.Silent.Regex.String()+"')", func(value string) error {
+		re, err := regexp.Compile(value)
+		opts.Silent.Regex = re
+		return err
+	})
+	fs.Func("tau-list", "the transition IDs or labels listed in `FILE` are"+
+		" silent", func(value string) error {
+		opts.Silent.List = ReadSilentList(value)
+		if !opts.Silent.enabled(SILENTLIST) {
+			opts.Silent.Detect = append(opts.Silent.Detect, SILENTLIST)
+		}
+		return nil
+	})
+	fs.StringVar(&opts.LabelFile, "labels", "", "CSV `FILE` with lines"+
+		" 'activity,model label' of the model labels\nof the log"+
+		" activities")
+	fs.Func("match", "label matching `MODE`: exact or normalized"+
+		" (case-insensitive\nignoring white space, '_' and '-')", func(value string) error {
+		if value != "exact" && value != "normalized" {
+			return fmt.Errorf("unknown matching: '%s'", value)
+		}
+		opts.Normalize = value == "normalized"
+		return nil
+	})
+	fs.Func("final", "final `MARKING`: full, or prefix to only require the"+
+		" log trace\nto be completed, for prefix alignments of running"+
+		" cases", func(value string) error {
+		if value != "full" && value != "prefix" {
+			return fmt.Errorf("unknown final marking: '%s'", value)
+		}
+		opts.Prefix = value == "prefix"
+		return nil
+	})
+	fs.IntVar(&opts.MaxSize, "max-size", 0, "no product is written if a"+
+		" product has more than `N` places,\ntransitions and arcs, 0 for"+
+		" unbounded")
+	return &opts
+}
+
+// defines the flags of the trace filters
+func addFilterFlags(fs *flag.FlagSet, opts *FilterOptions) {
+	fs.IntVar(&opts.MinLength, "min-length", 0, "select traces with at least"+
+		" `N` events")
+	fs.IntVar(&opts.MaxLength, "max-length", 0, "select traces with at most"+
+		" `N` events, 0 for unbounded")
+	fs.Var(listFlag{&opts.Contains}, "contains", "select traces with all"+
+		" comma-separated `ACTIVITIES`")
+	fs.Var(listFlag{&opts.Excludes}, "excludes", "select traces with none"+
+		" of the comma-separated `ACTIVITIES`")
+	fs.Func("attr", "select XES traces with the attribute `KEY=VALUE`, may"+
+		" be repeated", func(value string) error {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("expected KEY=VALUE, got '%s'", value)
+		}
+		if opts.Attributes == nil {
+			opts.Attributes = make(map[string]string)
+		}
+		opts.Attributes[kv[0]] = kv[1]
+		return nil
+	})
+	fs.Func("from", "select XES traces starting at or after `TIME`"+
+		" (YYYY-MM-DD or RFC 3339)", func(value string) (err error) {
+		opts.From, err = ParseFilterTime(value)
+		return
+	})
+	fs.Func("to", "select XES traces ending before `TIME`", func(
+		value string) (err error) {
+		opts.To, err = ParseFilterTime(value)
+		return
+	})
+	fs.IntVar(&opts.MinVariant, "min-variant", 0, "select traces of"+
+		" variants with at least `N` selected traces")
+	fs.IntVar(&opts.TopVariants, "top-variants", 0, "select traces of the"+
+		" `N` most frequent variants, 0 for all")
+	fs.IntVar(&opts.Sample, "sample", 0, "select a random sample of `N`"+
+		" of the selected traces, 0 for all")
+	fs.Int64Var(&opts.Seed, "seed", 0, "`SEED` of the random sample")
+}
+
+// defines the flags of the product names and solver trace pattern
+func addBatchFlags(fs *flag.FlagSet) (*string, *string) {
+	name := DEFAULTPRODUCTNAME
+	fs.Var(nameFlag{&name}, "name", "file name `PATTERN` of the products"+
+		" without extension")
+	return &name, addTraceFlag(fs)
+}
+
+func addTraceFlag(fs *flag.FlagSet) *string {
+	pattern := DEFAULTTRACEPATTERN
+	fs.Var(nameFlag{&pattern}, "trace", "file name `PATTERN` of the solver"+
+		" traces, with any trace\nextension if it has none")
+	return &pattern
+}
+
+func addOutputFlag(fs *flag.FlagSet) *string {
+	return fs.String("o", "text", "output `FORMAT` of the alignments: text"+
+		" or json")
+}
+
+func checkOutputFormat(fs *flag.FlagSet, format string) {
+	if format != "text" && format != "json" {
+		usageError(fs, "unknown output format '"+format+"'")
+	}
+}
+
+func usageError(fs *flag.FlagSet, msg string) {
+	fmt.Fprintln(fs.Output(), "Error: "+msg)
+	fs.Usage()
+	os.Exit(2)
+}
+
+// returns the command with the name or alias
+func findCommand(name string) *command {
+	for i, cmd := range commands {
+		if cmd.Name == name || cmd.Alias == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
 func showHelp() {
-	// TODO: provide output dir?
-	fmt.Println("USAGE:")
-	fmt.Printf("    %v  -p  MODEL.pnml  LOGFILE.{csv,xes}  OUTPUTDIR\n",
+	fmt.Printf("USAGE:\n    %s  COMMAND  [ARGUMENTS]  [OPTIONS]\n\n",
+		os.Args[0])
+	fmt.Println("COMMANDS:")
+	for _, cmd := range commands {
+		fmt.Printf("    %-12s%s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Printf("\nRun '%s COMMAND --help' for the arguments and options of"+
+		" a command,\nor '%s --version' for the version.\n", os.Args[0],
 		os.Args[0])
-	fmt.Printf("\n")
-	fmt.Printf("        %s\n", "Constructs a synchronous product"+
-		" for each log trace in LOGFILE.xes, to be\n        used in"+
-		"pnml2lts-sym for computing an alignment trace.\n        "+
-		"Two files are created per log trace x: 'syncmodel-x.pnml' and"+
-		" 'invariant-x.txt'")
-	fmt.Printf("\n")
-	fmt.Printf("    %v  -a  SYNCMODEL.pnml  TRACE.txt\n", os.Args[0])
-	fmt.Printf("\n")
-	fmt.Printf("        %s\n", "Constructs an alignment from the synchronous"+
-		" product and the log trace.\n        "+
-		"The resulting alignment is printed on the standard output")
-	fmt.Printf("\n")
-	fmt.Printf("    %v  -c  MODEL.pnml\n", os.Args[0])
-	fmt.Printf("\n")
-	fmt.Printf("        %s\n", "Returns the size of the Petri net model; the"+
-		" number of places, transitions and arcs")
-	//		"\n        A DOT file 'SYNCMODEL.dot' is also constructed")
-	os.Exit(0)
+}
+
+// parses the flags, which may also follow or be between the arguments, and
+// returns the arguments
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var ret []string
+	for {
+		fs.Parse(args) // exits on errors
+		args = fs.Args()
+		if len(args) == 0 {
+			return ret
+		}
+		ret = append(ret, args[0])
+		args = args[1:]
+	}
+}
+
+// parses the arguments of the command and runs it
+func (cmd *command) run(args []string) {
+	fs := flag.NewFlagSet(cmd.Name, flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		fmt.Fprintf(out, "USAGE:\n    %s  %s", os.Args[0], cmd.Name)
+		if cmd.Args != "" {
+			fmt.Fprintf(out, "  %s", cmd.Args)
+		}
+		if hasFlags {
+			fmt.Fprintf(out, "  [OPTIONS]")
+		}
+		fmt.Fprintf(out, "\n\n%s\n", cmd.Doc)
+		if cmd.Alias != "" && cmd.Alias != "-"+cmd.Name {
+			fmt.Fprintf(out, "\nAlias: %s\n", cmd.Alias)
+		}
+		if hasFlags {
+			fmt.Fprintln(out, "\nOPTIONS:")
+			fs.PrintDefaults()
+		}
+	}
+	action := cmd.Setup(fs)
+	args = parseArgs(fs, args)
+	if len(args) < cmd.MinArgs {
+		usageError(fs, "insufficient arguments")
+	}
+	if cmd.MaxArgs >= 0 && len(args) > cmd.MaxArgs {
+		usageError(fs, "too many arguments: '"+
+			strings.Join(args[cmd.MaxArgs:], " ")+"'")
+	}
+	action(args)
 }
 
 func CheckError(err error) {
@@ -52,27 +600,24 @@ func main() {
 	if len(os.Args) < 2 {
 		fmt.Println("Error: insufficient arguments")
 		showHelp()
+		os.Exit(2)
 	}
-	if os.Args[1] != "-a" && os.Args[1] != "-p" && os.Args[1] != "-c" {
-		fmt.Println("Error: unknown option: '" + os.Args[1] + "'")
-		showHelp()
-	} else if os.Args[1] == "-p" {
-		if len(os.Args) != 5 {
-			fmt.Println("Error: insufficient arguments")
-			showHelp()
-		}
-		CreatePNMLProduct(os.Args[2], os.Args[3], os.Args[4])
-	} else if os.Args[1] == "-a" {
-		if len(os.Args) != 4 {
-			fmt.Println("Error: insufficient arguments")
-			showHelp()
+	switch os.Args[1] {
+	case "help", "-h", "-help", "--help":
+		if len(os.Args) > 2 && findCommand(os.Args[2]) != nil {
+			findCommand(os.Args[2]).run([]string{"-help"})
 		}
-		TraceToAlign(os.Args[2], os.Args[3])
-	} else if os.Args[1] == "-c" {
-		if len(os.Args) != 3 {
-			fmt.Println("Error: insufficient arguments")
-			showHelp()
-		}
-		CheckModel(os.Args[2])
+		showHelp()
+		return
+	case "version", "-version", "--version":
+		fmt.Println("pnmlprod " + Version())
+		return
+	}
+	cmd := findCommand(os.Args[1])
+	if cmd == nil {
+		fmt.Println("Error: unknown command: '" + os.Args[1] + "'")
+		showHelp()
+		os.Exit(2)
 	}
+	cmd.run(os.Args[2:])
 }
@@ -2,12 +2,18 @@ package main
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type MarkingGraph struct {
 	Markings []MGMarking
 	Edges    []MGEdge
+	Initial  int               // ID of the initial marking
+	Final    []int             // IDs of the final markings
+	Names    map[string]string // place ID -> name
+	nextID   int
 }
 
 type MGPlace struct {
@@ -22,7 +28,8 @@ type MGEdge struct {
 	Type   string
 }
 
-var MGMarkingIDCount = 0
+// the ID of markings which are not (yet) in a marking graph
+const UNNUMBERED int = -1
 
 type MGMarking struct {
 	ID     int
@@ -31,17 +38,17 @@ type MGMarking struct {
 }
 
 func (pn *PNML) CreateMarkingGraph() MarkingGraph {
-	mg := MarkingGraph{}
-	// initial marking
-	InitMarking := MGMarking{info: "init", ID: MGMarkingIDCount}
-	MGMarkingIDCount += 1
+	mg := MarkingGraph{Names: make(map[string]string)}
 	for _, place := range pn.Net.Page.Places {
-		count, _ := strconv.Atoi(place.InitialMarking)
-		for ; count > 0; count -= 1 {
-			InitMarking.Add(place)
-		}
+		mg.Names[place.ID] = place.Name
+	}
+	// initial marking
+	InitMarking := mg.addMarking(pn.InitialMGMarking())
+	mg.Initial = InitMarking.ID
+	final := pn.FinalMGMarking()
+	if markingEquals(InitMarking, final) {
+		mg.Final = append(mg.Final, InitMarking.ID)
 	}
-	mg.Markings = append(mg.Markings, InitMarking)
 
 	V := []MGMarking{InitMarking}
 	Q := []MGMarking{InitMarking}
@@ -62,14 +69,23 @@ func (pn *PNML) CreateMarkingGraph() MarkingGraph {
 				}
 				if !found {
 					// add it to the lists
+					newM = mg.addMarking(newM)
+					targetID = newM.ID
 					Q = append(Q, newM)
 					V = append(V, newM)
-					mg.Markings = append(mg.Markings, newM)
+					if markingEquals(newM, final) {
+						mg.Final = append(mg.Final, newM.ID)
+					}
+				}
+				// products keep the label in OrigName
+				label := trans.OrigName
+				if label == "" {
+					label = trans.Name
 				}
 				mg.Edges = append(mg.Edges,
 					MGEdge{
 						ID:     trans.ID,
-						Label:  trans.OrigName,
+						Label:  label,
 						Type:   trans.Type,
 						Source: M.ID,
 						Target: targetID})
@@ -80,6 +96,36 @@ func (pn *PNML) CreateMarkingGraph() MarkingGraph {
 	return mg
 }
 
+// numbers the marking in the graph and adds it
+func (mg *MarkingGraph) addMarking(m MGMarking) MGMarking {
+	m.ID = mg.nextID
+	mg.nextID += 1
+	mg.Markings = append(mg.Markings, m)
+	return m
+}
+
+func (pn *PNML) InitialMGMarking() MGMarking {
+	ret := MGMarking{info: "init", ID: UNNUMBERED}
+	for _, place := range pn.Net.Page.Places {
+		count, _ := strconv.Atoi(place.InitialMarking)
+		for ; count > 0; count -= 1 {
+			ret.Add(place)
+		}
+	}
+	return ret
+}
+
+func (pn *PNML) FinalMGMarking() MGMarking {
+	ret := MGMarking{info: "final", ID: UNNUMBERED}
+	for _, mp := range pn.Net.FinalMarking.MPlaces {
+		count, _ := strconv.Atoi(mp.TokenCount)
+		for ; count > 0; count -= 1 {
+			ret.Places = append(ret.Places, MGPlace{ID: mp.ID})
+		}
+	}
+	return ret
+}
+
 func markingEquals(a, b MGMarking) bool {
 	if len(a.Places) != len(b.Places) {
 		return false
@@ -106,26 +152,26 @@ func markingEquals(a, b MGMarking) bool {
 }
 
 func (pn *PNML) CanFire(trans Transition, m MGMarking) bool {
-	// check the in-arcs
+	// check the in-arcs, parallel arcs need multiple tokens
+	needed := make(map[string]int)
 	for _, arc := range pn.Net.Page.Arcs {
 		if arc.Target == trans.ID {
-			found := false
-			for _, place := range m.Places {
-				if arc.Source == place.ID {
-					found = true
-				}
-			}
-			if !found {
-				return false
-			}
+			needed[arc.Source] += 1
+		}
+	}
+	for _, place := range m.Places {
+		needed[place.ID] -= 1
+	}
+	for _, n := range needed {
+		if n > 0 {
+			return false
 		}
 	}
 	return true
 }
 
 func (pn *PNML) Fire(trans Transition, m MGMarking) MGMarking {
-	newM := MGMarking{ID: MGMarkingIDCount}
-	MGMarkingIDCount += 1
+	newM := MGMarking{ID: UNNUMBERED}
 	for _, place := range m.Places {
 		newM.Places = append(newM.Places, MGPlace{ID: place.ID})
 	}
@@ -158,11 +204,69 @@ func (m *MGMarking) Add(place Place) {
 }
 
 func (m *MGMarking) Print() string {
-	ret := ""
+	var ret []string
 	for _, place := range m.Places {
-		ret += place.ID
+		ret = append(ret, place.ID)
+	}
+	return "[" + strings.Join(ret, ",") + "]"
+}
+
+// returns the marking as a multiset of place names, e.g. "{p1, 2*p2}",
+// places with the same name are told apart by their IDs, e.g. "p(id3)"
+func (mg *MarkingGraph) Multiset(m MGMarking) string {
+	shared := make(map[string]int) // name -> number of places
+	for _, name := range mg.Names {
+		shared[name] += 1
+	}
+	count := make(map[string]int)     // place ID -> tokens
+	labels := make(map[string]string) // place ID -> name
+	var ids []string
+	for _, place := range m.Places {
+		if count[place.ID] == 0 {
+			ids = append(ids, place.ID)
+			labels[place.ID] = mg.Names[place.ID]
+			if labels[place.ID] == "" {
+				labels[place.ID] = place.ID
+			} else if shared[labels[place.ID]] > 1 {
+				labels[place.ID] += "(" + place.ID + ")"
+			}
+		}
+		count[place.ID] += 1
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return labels[ids[i]] < labels[ids[j]]
+	})
+	var ret []string
+	for _, id := range ids {
+		if count[id] > 1 {
+			ret = append(ret, fmt.Sprintf("%d*%s", count[id], labels[id]))
+		} else {
+			ret = append(ret, labels[id])
+		}
+	}
+	return "{" + strings.Join(ret, ", ") + "}"
+}
+
+func (mg *MarkingGraph) isFinal(id int) bool {
+	for _, f := range mg.Final {
+		if f == id {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the fill colour of the marking, highlighting the initial and final
+// markings
+func (mg *MarkingGraph) dotFillColor(m MGMarking) string {
+	switch {
+	case m.ID == mg.Initial:
+		return "palegreen"
+	case mg.isFinal(m.ID):
+		return "lightsalmon"
+	default:
+		return "slategray1"
 	}
-	return ret
 }
 
 func (edge *MGEdge) dotColor() string {
@@ -179,22 +283,28 @@ func (edge *MGEdge) dotColor() string {
 	default:
 		return "black"
 	}
-	return "black"
 }
 func (mg *MarkingGraph) PrintDOT(filename string) {
 	ret := "digraph g {\n"
 	ret += "  rankdir=\"LR\";\n" // horizontal layout
-	// NB: add initial/final marking info?
+	// the initial marking is green, final markings are salmon with a double
+	// border
 	for _, marking := range mg.Markings {
+		peripheries := 1
+		if mg.isFinal(marking.ID) {
+			peripheries = 2
+		}
 		ret += fmt.Sprintf("  m%d [label=\"%s\",shape=box, "+
-			"style=\"filled,solid,rounded\", fillcolor=\"slategray1\", "+
-			"fontname=\"Courier-Bold\"];\n",
-			marking.ID, " ")
+			"style=\"filled,solid,rounded\", fillcolor=\"%s\", "+
+			"peripheries=%d, fontname=\"Courier-Bold\"];\n",
+			marking.ID, dotEscape(mg.Multiset(marking)),
+			mg.dotFillColor(marking), peripheries)
 	}
 	for _, edge := range mg.Edges {
 		ret += fmt.Sprintf("  m%d -> m%d [label=\"%s\", penwidth=2, color=\"%s\""+
 			", fontcolor=\"%s\"];\n",
-			edge.Source, edge.Target, edge.Label, edge.dotColor(), edge.dotColor())
+			edge.Source, edge.Target, dotEscape(edge.Label), edge.dotColor(),
+			edge.dotColor())
 	}
 
 	ret += "}\n"
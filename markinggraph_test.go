@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMultiset(t *testing.T) {
+	mg := MarkingGraph{Names: map[string]string{"p1": "start", "p2": "busy",
+		"p3": "busy", "p4": ""}}
+	m := MGMarking{Places: []MGPlace{{"p3"}, {"p1"}, {"p2"}, {"p3"},
+		{"p4"}}}
+	expected := "{busy(p2), 2*busy(p3), p4, start}"
+	if got := mg.Multiset(m); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
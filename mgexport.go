@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Exports of the marking graph for other verification tools: Aldebaran
+// (.aut) and a JSON adjacency list. The states are numbered in the order of
+// the markings, the initial marking is state 0.
+
+type MGJSON struct {
+	Initial int           `json:"initial"`
+	States  []MGJSONState `json:"states"`
+}
+
+type MGJSONState struct {
+	ID         int            `json:"id"`
+	Marking    map[string]int `json:"marking"` // place ID -> tokens
+	Initial    bool           `json:"initial,omitempty"`
+	Final      bool           `json:"final,omitempty"`
+	Successors []MGJSONEdge   `json:"successors"`
+}
+
+type MGJSONEdge struct {
+	Target     int    `json:"target"`
+	Label      string `json:"label"`
+	Type       string `json:"type"`
+	Transition string `json:"transition"`
+}
+
+// Returns the graph with the TAU edges collapsed: a marking has an edge with
+// a visible label to the markings reachable by TAU edges followed by that
+// edge, and it is final if a final marking is reachable by TAU edges. Only
+// the markings reachable from the initial marking remain.
+func (mg *MarkingGraph) CollapseTau() MarkingGraph {
+	markings := make(map[int]MGMarking)
+	for _, m := range mg.Markings {
+		markings[m.ID] = m
+	}
+	out := make(map[int][]MGEdge)
+	for _, edge := range mg.Edges {
+		out[edge.Source] = append(out[edge.Source], edge)
+	}
+	closure := func(id int) []int {
+		ret := []int{id}
+		visited := map[int]bool{id: true}
+		for i := 0; i < len(ret); i++ {
+			for _, edge := range out[ret[i]] {
+				if edge.Type == TAU && !visited[edge.Target] {
+					visited[edge.Target] = true
+					ret = append(ret, edge.Target)
+				}
+			}
+		}
+		return ret
+	}
+
+	ret := MarkingGraph{Initial: mg.Initial, Names: mg.Names}
+	visited := map[int]bool{mg.Initial: true}
+	added := make(map[string]bool)
+	Q := []int{mg.Initial}
+	for len(Q) > 0 {
+		id := Q[0]
+		Q = Q[1:]
+		ret.Markings = append(ret.Markings, markings[id])
+		for _, c := range closure(id) {
+			if mg.isFinal(c) && !ret.isFinal(id) {
+				ret.Final = append(ret.Final, id)
+			}
+			for _, edge := range out[c] {
+				key := fmt.Sprintf("%d-%d-%s-%s", id, edge.Target, edge.Type,
+					edge.Label)
+				if edge.Type == TAU || added[key] {
+					continue
+				}
+				added[key] = true
+				edge.Source = id
+				ret.Edges = append(ret.Edges, edge)
+				if !visited[edge.Target] {
+					visited[edge.Target] = true
+					Q = append(Q, edge.Target)
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// returns the state number of each marking ID
+func (mg *MarkingGraph) stateIndex() map[int]int {
+	ret := make(map[int]int)
+	for i, m := range mg.Markings {
+		ret[m.ID] = i
+	}
+	return ret
+}
+
+// returns the action label of the edge: "i" for TAU, the label for models
+// and the move type and label for products
+func (edge *MGEdge) autLabel(product bool) string {
+	switch {
+	case edge.Type == TAU:
+		return "i"
+	case product:
+		return edge.Type + " " + edge.Label
+	default:
+		return edge.Label
+	}
+}
+
+// returns whether the graph is of a synchronous product
+func (mg *MarkingGraph) isProduct() bool {
+	for _, edge := range mg.Edges {
+		if edge.Type == LOG || edge.Type == SYNC {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the graph in the Aldebaran format
+func (mg *MarkingGraph) ToAUT() string {
+	index := mg.stateIndex()
+	product := mg.isProduct()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "des (%d, %d, %d)\n", index[mg.Initial], len(mg.Edges),
+		len(mg.Markings))
+	for _, edge := range mg.Edges {
+		label := strings.ReplaceAll(edge.autLabel(product), "\"", "'")
+		fmt.Fprintf(&sb, "(%d, \"%s\", %d)\n", index[edge.Source], label,
+			index[edge.Target])
+	}
+	return sb.String()
+}
+
+// returns the graph as a JSON adjacency list
+func (mg *MarkingGraph) ToJSON() string {
+	index := mg.stateIndex()
+	ret := MGJSON{Initial: index[mg.Initial]}
+	for i, m := range mg.Markings {
+		state := MGJSONState{ID: i, Marking: make(map[string]int),
+			Initial: m.ID == mg.Initial, Final: mg.isFinal(m.ID),
+			Successors: []MGJSONEdge{}}
+		for _, place := range m.Places {
+			state.Marking[place.ID] += 1
+		}
+		ret.States = append(ret.States, state)
+	}
+	for _, edge := range mg.Edges {
+		s := &ret.States[index[edge.Source]]
+		s.Successors = append(s.Successors, MGJSONEdge{
+			Target: index[edge.Target], Label: edge.Label, Type: edge.Type,
+			Transition: edge.ID})
+	}
+	output, err := json.MarshalIndent(ret, "", "  ")
+	CheckError(err)
+	return string(output) + "\n"
+}
+
+// writes the marking graph of the net (or product) in the format of the
+// extension of outfn: dot, svg, aut or json
+func ExportMarkingGraph(netfn, outfn string, collapseTau bool) {
+	pn := readNet(netfn)
+	mg := pn.CreateMarkingGraph()
+	if collapseTau {
+		mg = mg.CollapseTau()
+	}
+	switch {
+	case strings.HasSuffix(outfn, ".svg"):
+		mg.PrintSVG(outfn)
+	case strings.HasSuffix(outfn, ".aut"):
+		WriteFile(outfn, mg.ToAUT())
+	case strings.HasSuffix(outfn, ".json"):
+		WriteFile(outfn, mg.ToJSON())
+	default:
+		mg.PrintDOT(outfn)
+	}
+}
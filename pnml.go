@@ -16,6 +16,8 @@ const (
 	TAUSYM string = "τ"
 )
 
+const MAXLINE int = 64 * 1024 * 1024 // maximal line length of input files
+
 var (
 	MOVES []string = []string{LOG, MODEL, SYNC, TAU}
 )
@@ -69,6 +71,16 @@ type Transition struct {
 	OrigName string   `xml:"origname>text"`
 	Type     string   `xml:"type>text"`     // added for {model,log,sync,tau}-moves
 	Selected string   `xml:"selected>text"` // for DOT printing
+
+	Model        string         `xml:"model>text,omitempty"` // of sync moves
+	ToolSpecific []ToolSpecific `xml:"toolspecific"`
+}
+
+type ToolSpecific struct {
+	XMLName  xml.Name `xml:"toolspecific"`
+	Tool     string   `xml:"tool,attr"`
+	Version  string   `xml:"version,attr"`
+	Activity string   `xml:"activity,attr,omitempty"`
 }
 
 type Arc struct {
@@ -90,6 +102,8 @@ func readPNML(filename string) []byte {
 	xml := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>"
 	strFile := ""
 	scanner := bufio.NewScanner(file)
+	// written PNML files consist of a single line
+	scanner.Buffer(make([]byte, 64*1024), MAXLINE)
 	for scanner.Scan() {
 		if strings.Contains(scanner.Text(), "<?xml") {
 			strFile += fmt.Sprintln(xml)
@@ -97,6 +111,7 @@ func readPNML(filename string) []byte {
 			strFile += fmt.Sprintln(scanner.Text())
 		}
 	}
+	CheckError(scanner.Err())
 	return []byte(strFile)
 }
 
@@ -210,7 +225,15 @@ func (pn *PNML) PrintDOT(filename string) {
 		}
 	}
 	ret += "  }\n"
-	// draw sync trans
+	// draw other places, such as the cost budget, and sync trans
+	for _, place := range pn.Net.Page.Places {
+		if place.Type != LOG && place.Type != MODEL {
+			ret += fmt.Sprintf("  %s [label=\"%s\", shape=circle"+
+				", style=\"filled,solid\", fillcolor=\"%s\""+
+				", fontname=\"Courier-Bold\"];\n",
+				place.ID, place.ID, dotTypeColor(place.Type, ""))
+		}
+	}
 	for _, trans := range pn.Net.Page.Transitions {
 		if trans.Type == SYNC {
 			ret += fmt.Sprintf("  %s [label=\"%s\", shape=box"+
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -35,21 +36,47 @@ func readLog(logfn string) [][]string {
 	return ret
 }
 
-func CreatePNMLProduct(modelfn, logfn, outdir string) {
-	_, err := os.Stat(outdir)
-	if os.IsNotExist(err) {
-		CheckError(errors.New("Directory doesn't exist '" + outdir + "'"))
+type ProductOptions struct {
+	Silent    SilentOptions
+	LabelFile string // mapping from log activities to model labels
+	Normalize bool   // case-insensitive, normalized label matching
+	Property  PropertyOptions
+	Formats   []string // output formats of the product, see writers.go
+	MaxSize   int      // maximal number of nodes and arcs, 0 for unbounded
+	Prefix    bool     // final marking only requires the log to be completed
+	Drawings  []string // drawings of the products, see layout.go
+	DrawModel bool     // also draw the model in the output directory
+	Name      string   // file name of product %d, without extension
+	// selection of the log traces, see logfilter.go
+	Filter FilterOptions
+}
+
+const DEFAULTPRODUCTNAME string = "syncmodel-%d"
+
+var DefaultProductOptions = ProductOptions{Silent: DefaultSilentOptions,
+	Property: DefaultPropertyOptions, Formats: []string{FMTPNML},
+	Drawings: []string{DRAWDOT}, Name: DEFAULTPRODUCTNAME}
+
+// checks that the file name pattern has a single %d for the trace index and
+// no directory
+func CheckNamePattern(pattern string) error {
+	if strings.Count(pattern, "%d") != 1 || strings.Count(pattern, "%") != 1 {
+		return errors.New("The file name pattern '" + pattern +
+			"' should have a single %d")
 	}
-	modelcontents := readPNML(modelfn)
-	logtraces := readLog(logfn)
+	if strings.ContainsRune(pattern, os.PathSeparator) ||
+		strings.Contains(pattern, "/") {
+		return errors.New("The file name pattern '" + pattern +
+			"' should not have a directory")
+	}
+	return nil
+}
+
+func CreatePNMLProduct(modelfn, logfn, outdir string, opts ProductOptions) {
+	model, logtraces, labels := prepareProducts(modelfn, logfn, outdir, opts)
 	for i, logtrace := range logtraces {
 		// creating the synchronous product
-		var pn PNML
-		xml.Unmarshal(modelcontents, &pn) // fill in PNML contents
-		pn.PostProcessPNML()              // fill in initial markings etc.
-		pn.PrintDOT(fmt.Sprintf("%s.dot", modelfn[:len(modelfn)-5]))
-		pn.AddLog(logtrace)
-		pn.PostProcessProduct()
+		pn := model.CreateProduct(logtrace, labels, opts)
 		// I/O
 		//fmt.Println("Printing synchronous PN")
 
@@ -57,17 +84,76 @@ func CreatePNMLProduct(modelfn, logfn, outdir string) {
 		//mg.PrintDOT(fmt.Sprintf("%s-mg.dot", modelfn[:len(modelfn)-5]))
 
 		//pn.Print()
-		pn.PrintDOT(fmt.Sprintf(outdir+"/syncmodel-%d.dot", i))
-		output, err := xml.Marshal(pn) // output PNML contents
-		CheckError(err)
-		WriteFile(fmt.Sprintf(outdir+"/syncmodel-%d.pnml", i), string(output))
-		WriteFile(fmt.Sprintf(outdir+"/invariant-%d.txt", i),
-			pn.GenerateInvariant())
+		pn.writeProduct(outdir, i, opts)
 	}
 }
 
-// should be called after unmarshalling
-func (pn *PNML) PostProcessPNML() {
+// reads the model and the selected traces of the log and writes the
+// manifest (with trace filters) and the reports on silent transitions,
+// labels and product sizes to outdir
+func prepareProducts(modelfn, logfn, outdir string,
+	opts ProductOptions) (PNML, [][]string, *LabelMap) {
+	_, err := os.Stat(outdir)
+	if os.IsNotExist(err) {
+		CheckError(errors.New("Directory doesn't exist '" + outdir + "'"))
+	}
+	CheckError(opts.Silent.Check())
+	CheckError(opts.Property.Check())
+	CheckError(CheckNamePattern(opts.Name))
+	for _, format := range opts.Formats {
+		CheckError(CheckFormat(format))
+	}
+	for _, drawing := range opts.Drawings {
+		CheckError(CheckDrawing(drawing))
+	}
+	model, silent := readModel(modelfn, opts.Silent) // PNML, BPMN or tree
+	WriteFile(outdir+"/silent.txt", SilentReport(silent))
+	if opts.DrawModel { // NB: not next to the model, which may be shared
+		model.writeDrawings(filepath.Join(outdir, strings.TrimSuffix(
+			filepath.Base(modelfn), filepath.Ext(modelfn))), opts.Drawings)
+	}
+	logtraces := readFilteredLog(logfn, outdir, opts.Filter)
+	labels := NewLabelMap(&model, opts.LabelFile, opts.Normalize)
+	WriteFile(outdir+"/labels.txt", labels.Report(&model, logtraces))
+	// check the product sizes before writing any of them
+	stats, err := model.DiagnoseProducts(logtraces, labels, opts.MaxSize)
+	diagnostics := DiagnosticsHeader()
+	for _, ps := range stats {
+		diagnostics += ps.toString()
+	}
+	WriteFile(outdir+"/diagnostics.csv", diagnostics)
+	CheckError(err)
+	return model, logtraces, labels
+}
+
+// returns the synchronous product of the model and the log trace
+func (pn *PNML) CreateProduct(logtrace []string, labels *LabelMap,
+	opts ProductOptions) PNML {
+	ret := pn.Copy()
+	ret.AddLog(logtrace, labels)
+	if opts.Prefix {
+		ret.SetPrefixFinalMarking()
+	}
+	if opts.Property.CostBound >= 0 {
+		ret.AddCostBudget(opts.Property.CostBound)
+	}
+	ret.PostProcessProduct()
+	return ret
+}
+
+// writes product i, its drawings and its property to outdir
+func (pn *PNML) writeProduct(outdir string, i int, opts ProductOptions) {
+	basename := filepath.Join(outdir, fmt.Sprintf(opts.Name, i))
+	pn.writeDrawings(basename, opts.Drawings)
+	for _, format := range opts.Formats {
+		pn.WriteNet(format, basename+"."+format)
+	}
+	WriteFile(outdir+"/"+opts.Property.FileName(i),
+		pn.GenerateProperty(opts.Property))
+}
+
+// should be called after unmarshalling, returns the silent transitions
+func (pn *PNML) PostProcessPNML(opts SilentOptions) []SilentTransition {
 	// change initialMarkings from "" to "0"
 	for i, _ := range pn.Net.Page.Places {
 		if len(pn.Net.Page.Places[i].InitialMarking) == 0 {
@@ -75,10 +161,13 @@ func (pn *PNML) PostProcessPNML() {
 		}
 		pn.Net.Page.Places[i].Type = MODEL
 	}
-	// change tau transitions to be TAU and add type TAU
-	// add type MODEL to all non-tau transitions
-	for i, _ := range pn.Net.Page.Transitions {
-		if strings.Contains(pn.Net.Page.Transitions[i].Name, "tau") {
+	// change silent transitions to be TAU and add type TAU
+	// add type MODEL to all other transitions
+	var silent []SilentTransition
+	for i, trans := range pn.Net.Page.Transitions {
+		if reason := opts.silentReason(trans); reason != "" {
+			silent = append(silent, SilentTransition{ID: trans.ID,
+				Name: trans.Name, Reason: reason})
 			pn.Net.Page.Transitions[i].Name = TAUSYM // τ
 			pn.Net.Page.Transitions[i].Type = TAU
 		} else {
@@ -90,6 +179,7 @@ func (pn *PNML) PostProcessPNML() {
 		CheckError(errors.New("Unable to parse final markings." +
 			" Is the PNML an accepting net?"))
 	}
+	return silent
 }
 
 func (pn *PNML) PostProcessProduct() {
@@ -141,7 +231,9 @@ func (pn *PNML) matchingModelTrans(name string) []transArcs {
 }
 
 // assumes the log trace is given as a CSV: "a,b,c,tau,s"
-func (pn *PNML) AddLog(logtrace []string) {
+// the label map decides which model transitions match an event, if nil the
+// labels should be equal
+func (pn *PNML) AddLog(logtrace []string, labels *LabelMap) {
 	if len(logtrace) == 0 {
 		return
 	}
@@ -171,11 +263,15 @@ func (pn *PNML) AddLog(logtrace []string) {
 		pn.Net.Page.Arcs = append(pn.Net.Page.Arcs, *a1, *a2)
 
 		// add sync moves for ALL matches in the model
-		for taid, ta := range pn.matchingModelTrans(letter) {
+		var matches []transArcs
+		for _, label := range labels.ModelLabels(letter) {
+			matches = append(matches, pn.matchingModelTrans(label)...)
+		}
+		for taid, ta := range matches {
 
 			ts := &Transition{XMLName: xml.Name{Space: "",
 				Local: "transition"}, ID: fmt.Sprintf("logs%dn%d", logid, taid),
-				Name: letter, Type: SYNC}
+				Name: letter, Type: SYNC, Model: ta.ID}
 			a3 := &Arc{XMLName: xml.Name{Space: "", Local: "arc"},
 				ID:     fmt.Sprintf("arcp%dn%d", logid, taid),
 				Name:   fmt.Sprintf("arcp%dn%d", logid, taid),
@@ -220,19 +316,5 @@ func (pn *PNML) AddLog(logtrace []string) {
 }
 
 func (pn *PNML) GenerateInvariant() string {
-	ret := "!("
-	first := true
-	for _, mp := range pn.Net.FinalMarking.MPlaces {
-		if mp.TokenCount != "0" {
-			if !first {
-				ret += " && "
-				first = false
-			} else {
-				first = false
-			}
-			ret += fmt.Sprintf("%s==%s", mp.ID, mp.TokenCount)
-		}
-	}
-	ret += ")"
-	return ret
+	return pn.GenerateProperty(DefaultPropertyOptions)
 }
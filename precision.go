@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Alignment-based precision (ETC): the model projections of the alignments
+// form a prefix automaton over the visible model labels. In each state of the
+// automaton, the visible labels enabled in the model (possibly after TAU
+// transitions) that are never observed in the log are escaping edges.
+
+type prefixState struct {
+	weight   int             // number of traces visiting the state
+	marking  MGMarking       // model marking of the first visit
+	observed map[string]bool // labels observed after the state
+}
+
+type PrecisionResult struct {
+	States    int
+	Escaping  int // weighted number of escaping edges
+	Enabled   int // weighted number of enabled labels
+	Precision float64
+	Edges     []string // escaping edges as "prefix -> label"
+}
+
+// returns the model marking of the product marking
+func (pn *PNML) projectMarking(m MGMarking) MGMarking {
+	ret := MGMarking{ID: m.ID}
+	for _, place := range m.Places {
+		for _, p := range pn.Net.Page.Places {
+			if p.ID == place.ID && p.Type == MODEL {
+				ret.Places = append(ret.Places, place)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// returns the visible labels enabled in the marking or after TAU transitions
+func (pn *PNML) enabledLabels(m MGMarking) map[string]bool {
+	ret := make(map[string]bool)
+	V := []MGMarking{m}
+	Q := []MGMarking{m}
+	for len(Q) > 0 && len(V) < TAULOOKAHEAD {
+		M := Q[0]
+		Q = Q[1:]
+		for _, trans := range pn.Net.Page.Transitions {
+			if !pn.CanFire(trans, M) {
+				continue
+			}
+			if trans.Type == MODEL {
+				ret[trans.Name] = true
+				continue
+			}
+			newM := pn.Fire(trans, M)
+			visited := false
+			for _, vm := range V {
+				if markingEquals(vm, newM) {
+					visited = true
+					break
+				}
+			}
+			if !visited {
+				V = append(V, newM)
+				Q = append(Q, newM)
+			}
+		}
+	}
+	return ret
+}
+
+// returns the label of the model transition of the pair, sync moves carry
+// the log label
+func (pn *PNML) modelLabel(ta *TraceAlignment, pair AlignPair) string {
+	id := ta.modelTransID(pair)
+	for _, trans := range pn.Net.Page.Transitions {
+		if trans.ID == id {
+			return trans.Name
+		}
+	}
+	return pair.Trans
+}
+
+// computes the precision of the (post-processed) model from the alignments
+func (pn *PNML) Precision(tas []TraceAlignment) PrecisionResult {
+	states := make(map[string]*prefixState)
+	var keys []string
+	visit := func(key string, m MGMarking) *prefixState {
+		s, ok := states[key]
+		if !ok {
+			s = &prefixState{marking: m, observed: make(map[string]bool)}
+			states[key] = s
+			keys = append(keys, key)
+		}
+		s.weight += 1
+		return s
+	}
+	for _, ta := range tas {
+		if ta.Err != nil {
+			continue
+		}
+		var prefix []string
+		s := visit("", pn.projectMarking(ta.Product.InitialMGMarking()))
+		ta.Replay(func(pair AlignPair, m MGMarking) {
+			if pair.Type == LOG || pair.Type == TAU {
+				return
+			}
+			label := pn.modelLabel(&ta, pair)
+			s.observed[label] = true
+			prefix = append(prefix, label)
+			s = visit(strings.Join(prefix, ","), pn.projectMarking(m))
+		})
+	}
+
+	res := PrecisionResult{States: len(states), Precision: 1}
+	for _, key := range keys {
+		s := states[key]
+		enabled := pn.enabledLabels(s.marking)
+		var escaping []string
+		for label, _ := range enabled {
+			if !s.observed[label] {
+				escaping = append(escaping, label)
+			}
+		}
+		sort.Strings(escaping)
+		for _, label := range escaping {
+			res.Edges = append(res.Edges, fmt.Sprintf("<%s> -> %s", key,
+				label))
+		}
+		res.Enabled += s.weight * len(enabled)
+		res.Escaping += s.weight * len(escaping)
+	}
+	if res.Enabled > 0 {
+		res.Precision = 1 - float64(res.Escaping)/float64(res.Enabled)
+	}
+	return res
+}
+
+func PrintPrecision(modelfn, logfn string, opts ProductOptions) {
+	CheckError(opts.Silent.Check())
+	model, _ := readModel(modelfn, opts.Silent)
+	logtraces := readLog(logfn)
+	labels := NewLabelMap(&model, opts.LabelFile, opts.Normalize)
+	tas := model.AlignTraces(logtraces, labels, opts)
+	for _, ta := range tas {
+		if ta.Err != nil {
+			fmt.Printf("trace %d not aligned: %v\n", ta.Trace, ta.Err)
+		}
+	}
+	res := model.Precision(tas)
+	fmt.Printf("Escaping edges (%d):\n", len(res.Edges))
+	for _, edge := range res.Edges {
+		fmt.Println("  " + edge)
+	}
+	fmt.Printf("states: %d, escaping: %d, enabled: %d\n", res.States,
+		res.Escaping, res.Enabled)
+	fmt.Printf("precision: %.4f\n", res.Precision)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// with a one-to-many label mapping, sync moves should be labelled by the
+// model transition they synchronize with
+func TestModelLabelMapping(t *testing.T) {
+	model, _ := readModel("testdata/small.pnml", DefaultSilentOptions)
+	labelfn := filepath.Join(t.TempDir(), "labels.csv")
+	WriteFile(labelfn, "x,a\nx,b\n")
+	labels := NewLabelMap(&model, labelfn, false)
+	ta := model.AlignTraces([][]string{{"a", "x"}}, labels,
+		DefaultProductOptions)[0]
+	if ta.Err != nil || ta.Cost != 0 {
+		t.Fatalf("expected cost 0, got %d (%v)", ta.Cost, ta.Err)
+	}
+	var got []string
+	ta.Replay(func(pair AlignPair, m MGMarking) {
+		if pair.Type == SYNC {
+			got = append(got, model.modelLabel(&ta, pair))
+		}
+	})
+	if !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("expected the labels [a b], got %v", got)
+	}
+}
+
+// the model side of sync pairs is the mapped model label, also in the
+// enumerated alignments, and repeated mapping lines add no sync transitions
+func TestSyncPairModelLabel(t *testing.T) {
+	model, _ := readModel("testdata/small.pnml", DefaultSilentOptions)
+	labelfn := filepath.Join(t.TempDir(), "labels.csv")
+	WriteFile(labelfn, "x,b\nx,b\n")
+	labels := NewLabelMap(&model, labelfn, false)
+	if got := labels.ModelLabels("x"); !reflect.DeepEqual(got,
+		[]string{"b"}) {
+		t.Errorf("expected the model labels [b], got %v", got)
+	}
+	ta := model.AlignTraces([][]string{{"a", "x"}}, labels,
+		DefaultProductOptions)[0]
+	if ta.Err != nil || ta.Cost != 0 {
+		t.Fatalf("expected cost 0, got %d (%v)", ta.Cost, ta.Err)
+	}
+	if _, n := countType(&ta.Product, SYNC); n != 2 {
+		t.Errorf("expected 2 sync transitions, got %d", n)
+	}
+	ss, err := ta.Product.exploreProduct()
+	if err != nil {
+		t.Fatal(err)
+	}
+	als := append(ss.enumerate(0), ta.Alignment)
+	for _, al := range als {
+		var got []string
+		for _, pair := range al.Pairs {
+			if pair.Type == SYNC {
+				got = append(got, pair.Log+"|"+pair.Trans)
+			}
+		}
+		if !reflect.DeepEqual(got, []string{"a|a", "x|b"}) {
+			t.Errorf("expected the sync pairs [a|a x|b], got %v", got)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Prefix alignments for running cases: the final marking of the product only
+// requires the log trace to be completed, the model may be in any state.
+
+// Restricts the final marking to the log places, should be called after
+// AddLog
+func (pn *PNML) SetPrefixFinalMarking() {
+	logPlaces := make(map[string]bool)
+	for _, place := range pn.Net.Page.Places {
+		if place.Type == LOG {
+			logPlaces[place.ID] = true
+		}
+	}
+	var mplaces []MPlace
+	for _, mp := range pn.Net.FinalMarking.MPlaces {
+		if logPlaces[mp.ID] {
+			mplaces = append(mplaces, mp)
+		}
+	}
+	pn.Net.FinalMarking.MPlaces = mplaces
+}
+
+// Online prefix alignments: the search of each running case is kept between
+// events. A search state is a model marking and the number of replayed
+// events, new events only add moves from the states that replayed all
+// previous events, so the distances found so far stay optimal and the
+// search is resumed from its frontier.
+
+type onlineState struct {
+	m      []int // model marking
+	events int   // number of replayed events
+	pred   int   // predecessor state, -1 for the initial state
+	pair   AlignPair
+}
+
+type onlineCase struct {
+	events  []string
+	cost    int
+	states  []onlineState
+	dist    []int
+	index   map[string]int // marking key and events -> state
+	settled []bool
+	layers  [][]int // events -> settled states
+	queue   searchQueue
+}
+
+type onlineAligner struct {
+	model   *PNML
+	labels  *LabelMap
+	tarr    TransArr
+	initial []int
+	final   map[int]int // place index -> tokens in the final marking
+	cases   map[string]*onlineCase
+}
+
+func newOnlineAligner(model *PNML, labels *LabelMap) *onlineAligner {
+	oa := &onlineAligner{model: model, labels: labels,
+		initial: make([]int, len(model.Net.Page.Places)),
+		final:   make(map[int]int), cases: make(map[string]*onlineCase)}
+	placeMap := make(map[string]int)
+	for i, place := range model.Net.Page.Places {
+		placeMap[place.ID] = i
+		oa.initial[i], _ = strconv.Atoi(place.InitialMarking)
+	}
+	oa.tarr = model.MakeTransArr(placeMap)
+	for _, mp := range model.Net.FinalMarking.MPlaces {
+		if p, ok := placeMap[mp.ID]; ok {
+			oa.final[p], _ = strconv.Atoi(mp.TokenCount)
+		}
+	}
+	return oa
+}
+
+func (oa *onlineAligner) isFinal(m []int) bool {
+	for p, n := range m {
+		if n != oa.final[p] {
+			return false
+		}
+	}
+	return true
+}
+
+func (oa *onlineAligner) newCase() *onlineCase {
+	c := &onlineCase{index: make(map[string]int)}
+	c.relax(onlineState{m: oa.initial, pred: -1}, 0)
+	return c
+}
+
+// adds the state or lowers its distance
+func (c *onlineCase) relax(st onlineState, d int) {
+	key := markingKey(st.m) + strconv.Itoa(st.events)
+	s, ok := c.index[key]
+	if !ok {
+		s = len(c.states)
+		c.index[key] = s
+		c.states = append(c.states, st)
+		c.dist = append(c.dist, d)
+		c.settled = append(c.settled, false)
+	} else if d >= c.dist[s] || c.settled[s] {
+		return
+	} else {
+		c.states[s] = st
+		c.dist[s] = d
+	}
+	heap.Push(&c.queue, searchItem{state: s, dist: d})
+}
+
+// relaxes the log and sync moves of the next event from state s
+func (oa *onlineAligner) expandEvent(c *onlineCase, s int) {
+	st := c.states[s]
+	letter := c.events[st.events]
+	c.relax(onlineState{m: st.m, events: st.events + 1, pred: s,
+		pair: AlignPair{Log: letter, Trans: SKIP,
+			TransID: fmt.Sprintf("logt%d", st.events), Type: LOG}},
+		c.dist[s]+1)
+	for _, label := range oa.labels.ModelLabels(letter) {
+		for _, trans := range oa.tarr.Trans {
+			if trans.Type != MODEL || trans.T.Name != label {
+				continue
+			}
+			if m := fireVector(trans, st.m); m != nil {
+				c.relax(onlineState{m: m, events: st.events + 1, pred: s,
+					pair: AlignPair{Log: letter, Trans: trans.T.OrigName,
+						TransID: trans.T.ID, Type: SYNC}}, c.dist[s])
+			}
+		}
+	}
+}
+
+func (oa *onlineAligner) expand(c *onlineCase, s int) {
+	st := c.states[s]
+	for _, trans := range oa.tarr.Trans {
+		if m := fireVector(trans, st.m); m != nil {
+			var al AlignmentS
+			al.AddPair(oa.model, trans.T)
+			c.relax(onlineState{m: m, events: st.events, pred: s,
+				pair: al.Pairs[0]}, c.dist[s]+moveCost(trans.Type))
+		}
+	}
+	if st.events < len(c.events) {
+		oa.expandEvent(c, s)
+	}
+}
+
+// resumes the search until a state satisfying goal is settled
+func (oa *onlineAligner) search(c *onlineCase,
+	goal func(st onlineState) bool) (int, error) {
+	for c.queue.Len() > 0 {
+		item := heap.Pop(&c.queue).(searchItem)
+		if c.settled[item.state] || item.dist > c.dist[item.state] {
+			continue
+		}
+		c.settled[item.state] = true
+		st := c.states[item.state]
+		for len(c.layers) <= st.events {
+			c.layers = append(c.layers, nil)
+		}
+		c.layers[st.events] = append(c.layers[st.events], item.state)
+		oa.expand(c, item.state)
+		if len(c.states) > MAXSTATES {
+			return -1, fmt.Errorf("Exceeded %d markings", MAXSTATES)
+		}
+		if goal(st) {
+			return item.state, nil
+		}
+	}
+	return -1, errors.New("The final marking is not reachable")
+}
+
+// returns the log and model moves of the prefix alignment ending in state s
+func (c *onlineCase) deviations(s int) []string {
+	var ret []string
+	for ; c.states[s].pred != -1; s = c.states[s].pred {
+		if pair := c.states[s].pair; pair.Type == LOG || pair.Type == MODEL {
+			ret = append([]string{pair.toString()}, ret...)
+		}
+	}
+	return ret
+}
+
+// replays the event of the case and returns the optimal prefix alignment
+// state, or with an empty activity completes the case and returns the
+// optimal alignment state
+func (oa *onlineAligner) event(caseID, activity string) (*onlineCase, int,
+	error) {
+	c, ok := oa.cases[caseID]
+	if !ok {
+		if activity == "" {
+			return nil, -1, errors.New("Unknown case '" + caseID + "'")
+		}
+		c = oa.newCase()
+		oa.cases[caseID] = c
+	}
+	n := len(c.events)
+	if activity == "" {
+		delete(oa.cases, caseID)
+		return c, 0, nil
+	}
+	c.events = append(c.events, activity)
+	if len(c.layers) > n {
+		for _, s := range c.layers[n] {
+			oa.expandEvent(c, s)
+		}
+	}
+	s, err := oa.search(c, func(st onlineState) bool {
+		return st.events == n+1
+	})
+	if err != nil {
+		delete(oa.cases, caseID)
+	}
+	return c, s, err
+}
+
+// completes the case: returns the optimal alignment state, which reaches the
+// final marking of the model after all events
+func (oa *onlineAligner) complete(c *onlineCase) (int, error) {
+	n := len(c.events)
+	for _, s := range c.layers[n] {
+		if oa.isFinal(c.states[s].m) {
+			return s, nil
+		}
+	}
+	return oa.search(c, func(st onlineState) bool {
+		return st.events == n && oa.isFinal(st.m)
+	})
+}
+
+// returns the output line of the optimal (prefix) alignment state s
+func (c *onlineCase) report(caseID, activity string, s int) string {
+	cost := c.dist[s]
+	status := "ok"
+	if cost > c.cost {
+		status = "DEVIATION " + strings.Join(c.deviations(s), " ")
+	}
+	c.cost = cost
+	return fmt.Sprintf("%s,%d,%s,%d,%s", caseID, len(c.events), activity,
+		cost, status)
+}
+
+// Reads events "CASE,ACTIVITY" from stdin and prints the cost of the optimal
+// prefix alignment of the case after each event. Deviations, i.e. events
+// increasing the cost, are flagged with the log and model moves of the new
+// prefix alignment. A case only ends with the end marker "CASE," after which
+// the cost of completing it is printed, as later events may follow when its
+// prefix alignment reaches the final marking of the model.
+func OnlineAlignments(modelfn string, opts ProductOptions) {
+	CheckError(opts.Silent.Check())
+	model, _ := readModel(modelfn, opts.Silent)
+	labels := NewLabelMap(&model, opts.LabelFile, opts.Normalize)
+	oa := newOnlineAligner(&model, labels)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	lineno := 0
+	for scanner.Scan() {
+		lineno += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		split := strings.SplitN(line, ",", 2)
+		if len(split) != 2 {
+			fmt.Printf("stdin:%d: expected 'case,activity': '%s'\n", lineno,
+				line)
+			continue
+		}
+		fmt.Println(oa.process(split[0], split[1]))
+	}
+	CheckError(scanner.Err())
+}
+
+// processes the event or end marker and returns the output lines
+func (oa *onlineAligner) process(caseID, activity string) string {
+	c, s, err := oa.event(caseID, activity)
+	if err == nil && activity == "" {
+		s, err = oa.complete(c)
+	}
+	if err != nil {
+		n := 0
+		if c != nil {
+			n = len(c.events)
+		}
+		return fmt.Sprintf("%s,%d,%s,error: %v", caseID, n, activity, err)
+	}
+	ret := c.report(caseID, activity, s)
+	if activity == "" {
+		return ret + ",end"
+	}
+	return ret
+}
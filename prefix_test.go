@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// the costs of the online prefix alignments should equal the optimal costs
+// of the prefix products, also when the events of the cases interleave
+func TestOnlineAlignments(t *testing.T) {
+	model, _ := readModel("model.pnml", DefaultSilentOptions)
+	logtraces := readLog("log.xes")
+	if len(logtraces) > 5 {
+		logtraces = logtraces[:5]
+	}
+	opts := DefaultProductOptions
+	opts.Prefix = true
+	oa := newOnlineAligner(&model, nil)
+	for j := 0; ; j++ {
+		done := true
+		for i, logtrace := range logtraces {
+			if j >= len(logtrace) {
+				continue
+			}
+			done = false
+			caseID := fmt.Sprint(i)
+			line := oa.process(caseID, logtrace[j])
+			pn := model.CreateProduct(logtrace[:j+1], nil, opts)
+			cost, _, err := pn.OptimalAlignments(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fields := strings.Split(line, ",")
+			if len(fields) < 5 || fields[3] != fmt.Sprint(cost) {
+				t.Errorf("trace %d, event %d: expected cost %d, got %s", i,
+					j, cost, line)
+			}
+			if _, ok := oa.cases[caseID]; !ok || strings.HasSuffix(line,
+				",end") {
+				t.Errorf("trace %d: the case should only end with the end"+
+					" marker: %s", i, line)
+			}
+		}
+		if done {
+			break
+		}
+	}
+}
+
+func TestOnlineEndMarker(t *testing.T) {
+	model, _ := readModel("testdata/small.pnml", DefaultSilentOptions)
+	oa := newOnlineAligner(&model, nil)
+	for _, c := range []struct{ activity, line string }{
+		{"a", "c,1,a,0,ok"},
+		{"x", "c,2,x,1,DEVIATION (x | » : logt1)"},
+		{"", "c,2,,2,DEVIATION (x | » : logt1) (» | b : t2),end"},
+		{"", "c,0,,error: Unknown case 'c'"},
+	} {
+		if line := oa.process("c", c.activity); line != c.line {
+			t.Errorf("expected '%s', got '%s'", c.line, line)
+		}
+	}
+	if len(oa.cases) != 0 {
+		t.Errorf("expected no running cases, got %d", len(oa.cases))
+	}
+}
+
+// reaching the final marking does not end the case, later events are log
+// moves of the same case
+func TestOnlineAfterFinalMarking(t *testing.T) {
+	model, _ := readModel("testdata/small.pnml", DefaultSilentOptions)
+	oa := newOnlineAligner(&model, nil)
+	for _, c := range []struct{ activity, line string }{
+		{"a", "c,1,a,0,ok"},
+		{"b", "c,2,b,0,ok"},
+		{"b", "c,3,b,1,DEVIATION (b | » : logt2)"},
+		{"", "c,3,,1,ok,end"},
+	} {
+		if line := oa.process("c", c.activity); line != c.line {
+			t.Errorf("expected '%s', got '%s'", c.line, line)
+		}
+	}
+	if len(oa.cases) != 0 {
+		t.Errorf("expected no running cases, got %d", len(oa.cases))
+	}
+}
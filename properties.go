@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Properties for the external model checker on the synchronous product. All
+// properties state that the final marking is never reached, such that a
+// counter example is an alignment.
+
+const (
+	PROPLTSMIN string = "ltsmin" // LTSmin invariant (predicate) syntax
+	PROPCTL    string = "ctl"
+	PROPLTL    string = "ltl"
+	BUDGETP    string = "budgetp" // place bounding the number of deviations
+	BUDGET     string = "BUDGET"  // type of the budget place
+)
+
+type PropertyOptions struct {
+	Format    string
+	CostBound int    // maximal number of log and model moves, -1 for none
+	Name      string // file name of property %d without extension or ""
+}
+
+var DefaultPropertyOptions = PropertyOptions{Format: PROPLTSMIN,
+	CostBound: -1}
+
+// returns the file name (excluding directory) of property i, by default
+// invariant-i.txt, property-i.ctl or property-i.ltl
+func (opts *PropertyOptions) FileName(i int) string {
+	name, ext := "property-%d", ".txt"
+	switch opts.Format {
+	case PROPCTL:
+		ext = ".ctl"
+	case PROPLTL:
+		ext = ".ltl"
+	default:
+		name = "invariant-%d"
+	}
+	if opts.Name != "" {
+		name = opts.Name
+	}
+	return fmt.Sprintf(name, i) + ext
+}
+
+func (opts *PropertyOptions) Check() error {
+	if opts.Format != PROPLTSMIN && opts.Format != PROPCTL &&
+		opts.Format != PROPLTL {
+		return errors.New("Unknown property format '" + opts.Format + "'")
+	}
+	if opts.CostBound < -1 {
+		return fmt.Errorf("Negative cost bound %d, use -1 for unbounded",
+			opts.CostBound)
+	}
+	if opts.Name != "" {
+		return CheckNamePattern(opts.Name)
+	}
+	return nil
+}
+
+// Adds a place with CostBound tokens that is consumed by every LOG and MODEL
+// move, such that the final marking can only be reached by an alignment with
+// at most CostBound deviations. Should be called after AddLog.
+func (pn *PNML) AddCostBudget(bound int) {
+	p := &Place{XMLName: xml.Name{Space: "", Local: "place"},
+		ID: BUDGETP, Name: BUDGETP, InitialMarking: fmt.Sprint(bound),
+		Type: BUDGET}
+	pn.Net.Page.Places = append(pn.Net.Page.Places, *p)
+	for _, trans := range pn.Net.Page.Transitions {
+		if trans.Type != LOG && trans.Type != MODEL {
+			continue
+		}
+		a := &Arc{XMLName: xml.Name{Space: "", Local: "arc"},
+			ID:     fmt.Sprintf("arcb%s", trans.ID),
+			Name:   fmt.Sprintf("arcb%s", trans.ID),
+			Source: BUDGETP,
+			Target: trans.ID}
+		pn.Net.Page.Arcs = append(pn.Net.Page.Arcs, *a)
+	}
+}
+
+// returns the conjunction of the final marking, ignoring empty places
+func (pn *PNML) finalMarkingPredicate() string {
+	var parts []string
+	for _, mp := range pn.Net.FinalMarking.MPlaces {
+		if mp.TokenCount != "0" {
+			parts = append(parts, fmt.Sprintf("%s==%s", mp.ID, mp.TokenCount))
+		}
+	}
+	return strings.Join(parts, " && ")
+}
+
+func (pn *PNML) GenerateProperty(opts PropertyOptions) string {
+	final := pn.finalMarkingPredicate()
+	switch opts.Format {
+	case PROPCTL:
+		return fmt.Sprintf("A [] !(%s)", final)
+	case PROPLTL:
+		return fmt.Sprintf("[] !(%s)", final)
+	default:
+		return fmt.Sprintf("!(%s)", final)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCostBudget(t *testing.T) {
+	model, pn := smallProduct().product()
+	logPlaces, _ := countType(&pn, LOG)
+	opts := DefaultProductOptions
+	opts.Property.CostBound = 2
+	bounded := model.CreateProduct(smallProduct().logtrace, nil, opts)
+	if n, _ := countType(&bounded, LOG); n != logPlaces {
+		t.Errorf("expected %d log places, got %d", logPlaces, n)
+	}
+	if n, _ := countType(&bounded, BUDGET); n != 1 {
+		t.Errorf("expected a budget place, got %d", n)
+	}
+	// exactly the log and model moves consume from the budget
+	charged := make(map[string]bool)
+	for _, trans := range bounded.Net.Page.Transitions {
+		if trans.Type == LOG || trans.Type == MODEL {
+			charged[trans.ID] = true
+		}
+	}
+	budget := make(map[string]bool)
+	for _, arc := range bounded.Net.Page.Arcs {
+		if arc.Source == BUDGETP {
+			budget[arc.Target] = true
+		}
+		if arc.Target == BUDGETP {
+			t.Errorf("unexpected arc %s into the budget", arc.ID)
+		}
+	}
+	if !reflect.DeepEqual(budget, charged) {
+		t.Errorf("expected budget arcs to %v, got %v", charged, budget)
+	}
+}
+
+func TestCostBudgetReachability(t *testing.T) {
+	pc := smallProduct()
+	model, pn := pc.product()
+	optimal, _, err := pn.OptimalAlignments(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultProductOptions
+	opts.Property.CostBound = optimal - 1
+	bounded := model.CreateProduct(pc.logtrace, nil, opts)
+	if _, _, err := bounded.OptimalAlignments(1); err == nil {
+		t.Errorf("the final marking is reachable with a budget of %d",
+			optimal-1)
+	}
+	opts.Property.CostBound = optimal
+	bounded = model.CreateProduct(pc.logtrace, nil, opts)
+	cost, _, err := bounded.OptimalAlignments(1)
+	if err != nil {
+		t.Fatalf("the final marking is not reachable with a budget of %d:"+
+			" %v", optimal, err)
+	}
+	if cost != optimal {
+		t.Errorf("expected cost %d, got %d", optimal, cost)
+	}
+}
+
+func TestPropertyCheck(t *testing.T) {
+	for bound, valid := range map[int]bool{-5: false, -2: false, -1: true,
+		0: true, 3: true} {
+		opts := DefaultPropertyOptions
+		opts.CostBound = bound
+		if err := opts.Check(); (err == nil) != valid {
+			t.Errorf("bound %d: unexpected result %v", bound, err)
+		}
+	}
+}
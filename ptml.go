@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+)
+
+// Importing process trees in ProM's PTML format. The tree is translated
+// recursively, where each node is translated between an entry and an exit
+// place. Silent steps (automaticTask) and the routing of the operators become
+// TAU transitions.
+
+type PTML struct {
+	XMLName xml.Name    `xml:"ptml"`
+	Tree    ProcessTree `xml:"processTree"`
+}
+
+type ProcessTree struct {
+	ID    string       `xml:"id,attr"`
+	Name  string       `xml:"name,attr"`
+	Root  string       `xml:"root,attr"`
+	Nodes []PTNode     `xml:",any"`
+	Edges []PTParentOf `xml:"parentsNode"`
+}
+
+type PTNode struct {
+	XMLName xml.Name
+	ID      string `xml:"id,attr"`
+	Name    string `xml:"name,attr"`
+}
+
+type PTParentOf struct {
+	ID       string `xml:"id,attr"`
+	SourceID string `xml:"sourceId,attr"`
+	TargetID string `xml:"targetId,attr"`
+}
+
+type ptTranslator struct {
+	nb       *netBuilder
+	nodes    map[string]PTNode
+	children map[string][]string // in order of the parentsNode elements
+}
+
+// reads the PTML file
+func parsePTML(filename string) PTML {
+	var ptml PTML
+	CheckError(xml.Unmarshal(readPNML(filename), &ptml))
+	return ptml
+}
+
+func ImportPTML(filename string) PNML {
+	tree := parsePTML(filename).Tree
+	pt := &ptTranslator{nb: newNetBuilder(tree.Name),
+		nodes: make(map[string]PTNode), children: make(map[string][]string)}
+	for _, node := range tree.Nodes {
+		if node.XMLName.Local != "parentsNode" {
+			pt.nodes[node.ID] = node
+		}
+	}
+	for _, edge := range tree.Edges {
+		pt.children[edge.SourceID] = append(pt.children[edge.SourceID],
+			edge.TargetID)
+	}
+	if _, ok := pt.nodes[tree.Root]; !ok {
+		CheckError(errors.New("Unable to find root node '" + tree.Root +
+			"' of process tree"))
+	}
+	source := pt.nb.addPlace("source")
+	sink := pt.nb.addPlace("sink")
+	pt.translate(tree.Root, source, sink)
+	pt.nb.setMarking(source, sink)
+	return pt.nb.pn
+}
+
+// translates the subtree rooted at id between the places in and out
+func (pt *ptTranslator) translate(id, in, out string) {
+	node := pt.nodes[id]
+	children := pt.children[id]
+	switch node.XMLName.Local {
+	case "manualTask":
+		pt.nb.addTransition(node.Name, []string{in}, []string{out})
+	case "automaticTask":
+		pt.nb.addTransition("", []string{in}, []string{out})
+	case "sequence":
+		if len(children) == 0 {
+			pt.nb.addTransition("", []string{in}, []string{out})
+		}
+		for i, child := range children {
+			next := out
+			if i < len(children)-1 {
+				next = pt.nb.addPlace(child + " done")
+			}
+			pt.translate(child, in, next)
+			in = next
+		}
+	case "xor":
+		for _, child := range children {
+			pt.translate(child, in, out)
+		}
+	case "and":
+		var starts, ends []string
+		for _, child := range children {
+			start := pt.nb.addPlace(child + " start")
+			end := pt.nb.addPlace(child + " end")
+			pt.translate(child, start, end)
+			starts = append(starts, start)
+			ends = append(ends, end)
+		}
+		pt.nb.addTransition("", []string{in}, starts)
+		pt.nb.addTransition("", ends, []string{out})
+	case "xorLoop":
+		if len(children) != 3 {
+			CheckError(errors.New("Loop node '" + id +
+				"' should have three children (do, redo, exit)"))
+		}
+		// the loop has its own entry place, as in may be shared with
+		// siblings of an xor
+		start := pt.nb.addPlace(id + " start")
+		mid := pt.nb.addPlace(id + " mid")
+		pt.nb.addTransition("", []string{in}, []string{start})
+		pt.translate(children[0], start, mid)
+		pt.translate(children[1], mid, start)
+		pt.translate(children[2], mid, out)
+	default:
+		CheckError(errors.New("Unsupported process tree node '" +
+			node.XMLName.Local + "' (" + id + ")"))
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"testing"
+)
+
+// the tree is ->(X(a, b), +(c, d), *(e, tau, tau))
+func TestImportPTML(t *testing.T) {
+	pn := ImportPTML("testdata/tree.ptml")
+	if _, visible := countType(&pn, MODEL); visible != 5 {
+		t.Errorf("expected 5 visible transitions, got %d", visible)
+	}
+	checkCosts(t, pn, map[string]int{"a,c,d,e": 0, "b,d,c,e,e,e": 0,
+		"a,b,c,d,e": 1, "a,c,e": 1, "a,c,d": 1, "c,a,d,e": 2})
+}
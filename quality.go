@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// Model quality in the four dimensions: alignment-based fitness, ETC
+// precision, generalization from the number of times each model transition
+// is executed by the aligned traces, and simplicity from the structure of the
+// net.
+
+type SimplicityResult struct {
+	Places            int
+	Transitions       int
+	Arcs              int
+	Size              int     // places + transitions
+	AvgDegree         float64 // average number of arcs of a node
+	MaxDegree         int
+	Cyclomatic        int // arcs - nodes + connected components
+	ConnectorMismatch int
+}
+
+type GeneralizationResult struct {
+	Executions     map[string]int // model transition ID -> executions
+	Generalization float64
+}
+
+type QualityResult struct {
+	Fitness        float64
+	Precision      PrecisionResult
+	Generalization GeneralizationResult
+	Simplicity     SimplicityResult
+}
+
+// Computes the structural simplicity measures of the net. The connector
+// mismatch sums, for XOR (places) and AND (transitions) connectors, the
+// difference between the arcs leaving splits and the arcs entering joins.
+func (pn *PNML) Simplicity() SimplicityResult {
+	page := &pn.Net.Page
+	res := SimplicityResult{Places: len(page.Places),
+		Transitions: len(page.Transitions), Arcs: len(page.Arcs)}
+	res.Size = res.Places + res.Transitions
+
+	in := make(map[string]int)
+	out := make(map[string]int)
+	parent := make(map[string]string)
+	var find func(id string) string
+	find = func(id string) string {
+		if parent[id] == id {
+			return id
+		}
+		parent[id] = find(parent[id])
+		return parent[id]
+	}
+	for _, place := range page.Places {
+		parent[place.ID] = place.ID
+	}
+	for _, trans := range page.Transitions {
+		parent[trans.ID] = trans.ID
+	}
+	components := res.Size
+	for _, arc := range page.Arcs {
+		out[arc.Source] += 1
+		in[arc.Target] += 1
+		_, ok1 := parent[arc.Source]
+		_, ok2 := parent[arc.Target]
+		if !ok1 || !ok2 {
+			continue
+		}
+		if a, b := find(arc.Source), find(arc.Target); a != b {
+			parent[a] = b
+			components -= 1
+		}
+	}
+	if res.Size > 0 {
+		res.AvgDegree = float64(2*res.Arcs) / float64(res.Size)
+	}
+	res.Cyclomatic = res.Arcs - res.Size + components
+
+	// returns the arcs leaving splits minus the arcs entering joins
+	mismatch := func(ids []string) int {
+		ret := 0
+		for _, id := range ids {
+			if in[id]+out[id] > res.MaxDegree {
+				res.MaxDegree = in[id] + out[id]
+			}
+			if out[id] > 1 {
+				ret += out[id]
+			}
+			if in[id] > 1 {
+				ret -= in[id]
+			}
+		}
+		if ret < 0 {
+			return -ret
+		}
+		return ret
+	}
+	var places, transitions []string
+	for _, place := range page.Places {
+		places = append(places, place.ID)
+	}
+	for _, trans := range page.Transitions {
+		transitions = append(transitions, trans.ID)
+	}
+	res.ConnectorMismatch = mismatch(places) + mismatch(transitions)
+	return res
+}
+
+// Computes the generalization 1 - avg(1/sqrt(executions)) over the model
+// transitions, transitions never executed count as 1
+func (pn *PNML) Generalization(tas []TraceAlignment) GeneralizationResult {
+	res := GeneralizationResult{Executions: make(map[string]int)}
+	for _, trans := range pn.Net.Page.Transitions {
+		res.Executions[trans.ID] = 0
+	}
+	for _, ta := range tas {
+		if ta.Err != nil {
+			continue
+		}
+		for _, pair := range ta.Alignment.Pairs {
+			if pair.Type == LOG {
+				continue
+			}
+			if id := ta.modelTransID(pair); id != "" {
+				res.Executions[id] += 1
+			}
+		}
+	}
+	if len(res.Executions) == 0 {
+		return res
+	}
+	sum := 0.0
+	for _, n := range res.Executions {
+		if n == 0 {
+			sum += 1
+		} else {
+			sum += 1 / math.Sqrt(float64(n))
+		}
+	}
+	res.Generalization = 1 - sum/float64(len(res.Executions))
+	return res
+}
+
+// Computes the alignment-based fitness 1 - cost / (|trace| + cost of the
+// empty trace), summed over the aligned traces
+func (pn *PNML) AlignmentFitness(tas []TraceAlignment,
+	opts ProductOptions) (float64, error) {
+	empty := pn.CreateProduct(nil, nil, opts)
+	emptyCost, _, err := empty.OptimalAlignments(1)
+	if err != nil {
+		return 0, err
+	}
+	cost, worst := 0, 0
+	for _, ta := range tas {
+		if ta.Err != nil {
+			continue
+		}
+		cost += ta.Cost
+		for _, pair := range ta.Alignment.Pairs {
+			if pair.Type == LOG || pair.Type == SYNC {
+				worst += 1
+			}
+		}
+		worst += emptyCost
+	}
+	if worst == 0 {
+		return 1, nil
+	}
+	return 1 - float64(cost)/float64(worst), nil
+}
+
+func (pn *PNML) Quality(tas []TraceAlignment,
+	opts ProductOptions) (QualityResult, error) {
+	var res QualityResult
+	var err error
+	res.Fitness, err = pn.AlignmentFitness(tas, opts)
+	if err != nil {
+		return res, err
+	}
+	res.Precision = pn.Precision(tas)
+	res.Generalization = pn.Generalization(tas)
+	res.Simplicity = pn.Simplicity()
+	return res, nil
+}
+
+// prints the quality of the model, from the optimal alignments of the log or,
+// if input is a directory, from the alignments reconstructed from the solver
+// traces of its products
+func PrintQuality(modelfn, input, pattern string, opts ProductOptions) {
+	CheckError(opts.Silent.Check())
+	model, _ := readModel(modelfn, opts.Silent)
+	var tas []TraceAlignment
+	if info, err := os.Stat(input); err == nil && info.IsDir() {
+		tas = SolverAlignments(input, opts.Name, pattern)
+	} else {
+		logtraces := readLog(input)
+		labels := NewLabelMap(&model, opts.LabelFile, opts.Normalize)
+		tas = model.AlignTraces(logtraces, labels, opts)
+	}
+	aligned := 0
+	for _, ta := range tas {
+		if ta.Err != nil {
+			fmt.Printf("trace %d not aligned: %v\n", ta.Trace, ta.Err)
+		} else {
+			aligned += 1
+		}
+	}
+	res, err := model.Quality(tas, opts)
+	CheckError(err)
+
+	gen := res.Generalization
+	var ids []string
+	for id, _ := range gen.Executions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if gen.Executions[ids[i]] != gen.Executions[ids[j]] {
+			return gen.Executions[ids[i]] < gen.Executions[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	fmt.Println("Transition executions:")
+	for _, trans := range ids {
+		name := trans
+		for _, t := range model.Net.Page.Transitions {
+			if t.ID == trans {
+				name = fmt.Sprintf("%s (%s)", t.ID, t.Name)
+				break
+			}
+		}
+		fmt.Printf("  %s: %d\n", name, gen.Executions[trans])
+	}
+
+	s := res.Simplicity
+	fmt.Printf("traces: %d, aligned: %d\n", len(tas), aligned)
+	fmt.Printf("fitness: %.4f\n", res.Fitness)
+	fmt.Printf("precision: %.4f\n", res.Precision.Precision)
+	fmt.Printf("generalization: %.4f\n", gen.Generalization)
+	fmt.Printf("simplicity: size %d (%d places, %d transitions), arcs %d,"+
+		" avg degree %.2f, max degree %d, cyclomatic number %d, connector"+
+		" mismatch %d\n", s.Size, s.Places, s.Transitions, s.Arcs,
+		s.AvgDegree, s.MaxDegree, s.Cyclomatic, s.ConnectorMismatch)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// the metrics of the alignments reconstructed from the solver traces should
+// equal those of the native search
+func TestQualitySolverAlignments(t *testing.T) {
+	trace, err := filepath.Abs("testdata/small-trace.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	logfn := filepath.Join(dir, "log.csv")
+	WriteFile(logfn, "a,x,b\n")
+	opts := DefaultProductOptions
+	opts.Drawings = nil
+	RunPipeline("testdata/small.pnml", logfn, dir, opts, RunOptions{
+		Command: "cp '" + trace + "' {trace}", TraceExt: ".txt",
+		Timeout: time.Minute, Jobs: 1})
+
+	model, _ := readModel("testdata/small.pnml", DefaultSilentOptions)
+	solver := SolverAlignments(dir, opts.Name, DEFAULTTRACEPATTERN)
+	if len(solver) != 1 || solver[0].Err != nil {
+		t.Fatalf("expected one solver alignment, got %v", solver)
+	}
+	native := model.AlignTraces(readLog(logfn), nil, opts)
+	expected, err := model.Quality(native, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := model.Quality(solver, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if n := got.Generalization.Executions["t2"]; n != 1 {
+		t.Errorf("expected 1 execution of t2, got %d", n)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Token-based replay of log traces on the model, using the firing rule of the
+// marking graph. Events are replayed on a transition with a matching label;
+// if none is enabled, a sequence of TAU transitions enabling one is searched
+// for, otherwise the missing tokens are created. Events without a model
+// transition are replayed on a transition of their own, which misses the token
+// it consumes and leaves the token it produces.
+
+// limit on the number of markings visited when searching for TAU transitions
+const TAULOOKAHEAD int = 1000
+
+type ReplayResult struct {
+	Trace     int
+	Events    int
+	Unmapped  int // events without a model transition
+	Produced  int
+	Consumed  int
+	Missing   int
+	Remaining int
+}
+
+// fitness = 1/2 (1 - missing/consumed) + 1/2 (1 - remaining/produced)
+func replayFitness(produced, consumed, missing, remaining int) float64 {
+	ret := 1.0
+	if consumed > 0 {
+		ret -= 0.5 * float64(missing) / float64(consumed)
+	}
+	if produced > 0 {
+		ret -= 0.5 * float64(remaining) / float64(produced)
+	}
+	return ret
+}
+
+func (rr *ReplayResult) Fitness() float64 {
+	return replayFitness(rr.Produced, rr.Consumed, rr.Missing, rr.Remaining)
+}
+
+type tokenReplayer struct {
+	pn     *PNML
+	labels *LabelMap
+	taus   []Transition
+	in     map[string]map[string]int // transition ID -> place -> arc count
+	out    map[string]int            // transition ID -> number of out arcs
+}
+
+func (pn *PNML) newTokenReplayer(labels *LabelMap) *tokenReplayer {
+	tr := &tokenReplayer{pn: pn, labels: labels,
+		in: make(map[string]map[string]int), out: make(map[string]int)}
+	for _, trans := range pn.Net.Page.Transitions {
+		if trans.Type == TAU {
+			tr.taus = append(tr.taus, trans)
+		}
+		tr.in[trans.ID] = make(map[string]int)
+	}
+	for _, arc := range pn.Net.Page.Arcs {
+		if _, ok := tr.in[arc.Target]; ok {
+			tr.in[arc.Target][arc.Source] += 1
+		}
+		if _, ok := tr.in[arc.Source]; ok {
+			tr.out[arc.Source] += 1
+		}
+	}
+	return tr
+}
+
+// returns the number of tokens missing to fire the transition in m
+func (tr *tokenReplayer) missing(trans Transition, m MGMarking) int {
+	count := make(map[string]int)
+	for _, place := range m.Places {
+		count[place.ID] += 1
+	}
+	ret := 0
+	for place, n := range tr.in[trans.ID] {
+		if n > count[place] {
+			ret += n - count[place]
+		}
+	}
+	return ret
+}
+
+// fires the transition, creating missing tokens
+func (tr *tokenReplayer) fire(trans Transition, m MGMarking,
+	rr *ReplayResult) MGMarking {
+	rr.Missing += tr.missing(trans, m)
+	for _, n := range tr.in[trans.ID] {
+		rr.Consumed += n
+	}
+	rr.Produced += tr.out[trans.ID]
+	return tr.pn.Fire(trans, m)
+}
+
+// breadth-first search over TAU transitions for a marking satisfying goal,
+// returns the TAU transitions to fire and whether such a marking is found
+func (tr *tokenReplayer) tauPath(m MGMarking,
+	goal func(MGMarking) bool) ([]Transition, bool) {
+	type node struct {
+		m    MGMarking
+		path []Transition
+	}
+	if goal(m) {
+		return nil, true
+	}
+	V := []MGMarking{m}
+	Q := []node{{m: m}}
+	for len(Q) > 0 && len(V) < TAULOOKAHEAD {
+		n := Q[0]
+		Q = Q[1:]
+		for _, trans := range tr.taus {
+			if !tr.pn.CanFire(trans, n.m) {
+				continue
+			}
+			newM := tr.pn.Fire(trans, n.m)
+			visited := false
+			for _, vm := range V {
+				if markingEquals(vm, newM) {
+					visited = true
+					break
+				}
+			}
+			if visited {
+				continue
+			}
+			path := append(append([]Transition{}, n.path...), trans)
+			if goal(newM) {
+				return path, true
+			}
+			V = append(V, newM)
+			Q = append(Q, node{m: newM, path: path})
+		}
+	}
+	return nil, false
+}
+
+func (tr *tokenReplayer) Replay(logtrace []string) ReplayResult {
+	rr := ReplayResult{Events: len(logtrace)}
+	m := tr.pn.InitialMGMarking()
+	rr.Produced += len(m.Places)
+	for _, letter := range logtrace {
+		var cands []Transition
+		for _, label := range tr.labels.ModelLabels(letter) {
+			for _, trans := range tr.pn.Net.Page.Transitions {
+				if trans.Type == MODEL && trans.Name == label {
+					cands = append(cands, trans)
+				}
+			}
+		}
+		if len(cands) == 0 {
+			rr.Unmapped += 1
+			rr.Consumed += 1
+			rr.Missing += 1
+			rr.Produced += 1
+			rr.Remaining += 1
+			continue
+		}
+		var enabled *Transition
+		path, found := tr.tauPath(m, func(m MGMarking) bool {
+			for i, _ := range cands {
+				if tr.missing(cands[i], m) == 0 {
+					enabled = &cands[i]
+					return true
+				}
+			}
+			return false
+		})
+		if found {
+			for _, tau := range path {
+				m = tr.fire(tau, m, &rr)
+			}
+		} else {
+			// fire the candidate with the fewest missing tokens
+			enabled = &cands[0]
+			for i, _ := range cands {
+				if tr.missing(cands[i], m) < tr.missing(*enabled, m) {
+					enabled = &cands[i]
+				}
+			}
+		}
+		m = tr.fire(*enabled, m, &rr)
+	}
+	// try to reach the final marking with TAU transitions
+	final := tr.pn.FinalMGMarking()
+	path, _ := tr.tauPath(m, func(m MGMarking) bool {
+		return markingEquals(m, final)
+	})
+	for _, tau := range path {
+		m = tr.fire(tau, m, &rr)
+	}
+	// consume the final marking
+	rr.Consumed += len(final.Places)
+	count := make(map[string]int)
+	for _, place := range m.Places {
+		count[place.ID] += 1
+	}
+	for _, place := range final.Places {
+		if count[place.ID] > 0 {
+			count[place.ID] -= 1
+		} else {
+			rr.Missing += 1
+		}
+	}
+	for _, n := range count {
+		rr.Remaining += n
+	}
+	return rr
+}
+
+func ReplayLog(modelfn, logfn string, opts ProductOptions) {
+	CheckError(opts.Silent.Check())
+	model, _ := readModel(modelfn, opts.Silent)
+	logtraces := readLog(logfn)
+	labels := NewLabelMap(&model, opts.LabelFile, opts.Normalize)
+	tr := model.newTokenReplayer(labels)
+	total := ReplayResult{}
+	fmt.Println("trace,events,unmapped,produced,consumed,missing,remaining," +
+		"fitness")
+	for i, logtrace := range logtraces {
+		rr := tr.Replay(logtrace)
+		rr.Trace = i
+		fmt.Printf("%d,%d,%d,%d,%d,%d,%d,%.4f\n", i, rr.Events, rr.Unmapped,
+			rr.Produced, rr.Consumed, rr.Missing, rr.Remaining, rr.Fitness())
+		total.Events += rr.Events
+		total.Unmapped += rr.Unmapped
+		total.Produced += rr.Produced
+		total.Consumed += rr.Consumed
+		total.Missing += rr.Missing
+		total.Remaining += rr.Remaining
+	}
+	fmt.Printf("log,%d,%d,%d,%d,%d,%d,%.4f\n", total.Events, total.Unmapped,
+		total.Produced, total.Consumed, total.Missing, total.Remaining,
+		total.Fitness())
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestReplayUnmapped(t *testing.T) {
+	model, _ := readModel("testdata/small.pnml", DefaultSilentOptions)
+	tr := model.newTokenReplayer(NewLabelMap(&model, "", false))
+	fitting := tr.Replay([]string{"a", "b"})
+	if fitting.Missing != 0 || fitting.Remaining != 0 ||
+		fitting.Fitness() != 1 {
+		t.Errorf("expected a fitting trace, got %+v", fitting)
+	}
+	// the unmapped event x costs one missing and one remaining token
+	rr := tr.Replay([]string{"a", "x", "b"})
+	expected := ReplayResult{Events: 3, Unmapped: 1,
+		Produced: fitting.Produced + 1, Consumed: fitting.Consumed + 1,
+		Missing: 1, Remaining: 1}
+	if rr != expected {
+		t.Errorf("expected %+v, got %+v", expected, rr)
+	}
+	if rr.Fitness() >= 1 {
+		t.Errorf("expected a fitness below 1, got %f", rr.Fitness())
+	}
+}
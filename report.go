@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+)
+
+// Self-contained HTML report of the alignments: an index of the traces that
+// can be sorted by cost, and per trace the alignment as a table of a log row
+// and a model row, coloured by move type as in the DOT output, with the
+// drawing of the product where the transitions of the alignment are
+// selected.
+
+type reportEntry struct {
+	Trace     int
+	Cost      int
+	Err       string
+	Alignment AlignmentS
+	Product   *PNML // nil if not available
+}
+
+const reportStyle string = `
+body { font-family: sans-serif; margin: 2em; }
+table.index { border-collapse: collapse; }
+table.index th { cursor: pointer; background: #eee; }
+table.index th, table.index td { border: 1px solid #ccc; padding: 2px 8px; }
+table.alignment { border-collapse: collapse; font-family: monospace;
+  font-weight: bold; margin: 1em 0; }
+table.alignment td, table.alignment th { border: 1px solid #999;
+  padding: 2px 6px; text-align: center; }
+.error { color: firebrick; }
+.net { overflow: auto; max-height: 40em; border: 1px solid #ccc; }
+`
+
+// sorts the index on clicking a column header, numerically if possible
+const reportScript string = `
+document.querySelectorAll("table.index th").forEach(function(th, col) {
+  th.addEventListener("click", function() {
+    var tbody = th.closest("table").tBodies[0];
+    var rows = Array.from(tbody.rows);
+    var asc = th.dataset.order !== "asc";
+    th.dataset.order = asc ? "asc" : "desc";
+    rows.sort(function(a, b) {
+      var x = a.cells[col].dataset.value, y = b.cells[col].dataset.value;
+      var d = (isNaN(x) || isNaN(y)) ? x.localeCompare(y) : x - y;
+      return asc ? d : -d;
+    });
+    rows.forEach(function(row) { tbody.appendChild(row); });
+  });
+});
+`
+
+// returns the alignment as a table with a log row and a model row
+func alignmentTable(al AlignmentS) string {
+	logRow, modelRow := "", ""
+	for _, pair := range al.Pairs {
+		style := fmt.Sprintf(" style=\"background: %s\" title=\"%s %s\"",
+			svgColor(dotTypeColor(pair.Type, "")), pair.Type,
+			html.EscapeString(pair.TransID))
+		logRow += fmt.Sprintf("<td%s>%s</td>", style,
+			html.EscapeString(pair.Log))
+		modelRow += fmt.Sprintf("<td%s>%s</td>", style,
+			html.EscapeString(pair.Trans))
+	}
+	return "<table class=\"alignment\">\n<tr><th>log</th>" + logRow +
+		"</tr>\n<tr><th>model</th>" + modelRow + "</tr>\n</table>\n"
+}
+
+// returns the product drawing with the transitions of the alignment selected
+func selectedProductSVG(pn *PNML, al AlignmentS, prefix string) string {
+	selected := make(map[string]bool)
+	for _, pair := range al.Pairs {
+		selected[pair.TransID] = true
+	}
+	product := *pn
+	product.Net.Page.Transitions = make([]Transition,
+		len(pn.Net.Page.Transitions))
+	for i, trans := range pn.Net.Page.Transitions {
+		if selected[trans.ID] {
+			trans.Selected = "true"
+		}
+		product.Net.Page.Transitions[i] = trans
+	}
+	g := product.layoutGraph()
+	g.Prefix = prefix
+	return strings.TrimPrefix(g.SVG(), SVGHEADER)
+}
+
+func legend() string {
+	ret := "<p>"
+	for _, t := range MOVES {
+		ret += fmt.Sprintf("<span style=\"background: %s; padding: 2px"+
+			" 6px\">%s</span> ", svgColor(dotTypeColor(t, "")), t)
+	}
+	return ret + "</p>\n"
+}
+
+// returns the HTML report of the entries
+func HTMLReport(title string, entries []reportEntry) string {
+	var sb strings.Builder
+	aligned, cost := 0, 0
+	for _, e := range entries {
+		if e.Err == "" {
+			aligned += 1
+			cost += e.Cost
+		}
+	}
+	fmt.Fprintf(&sb, "<!DOCTYPE html>\n<html>\n<head>\n<meta"+
+		" charset=\"UTF-8\">\n<title>%s</title>\n<style>%s</style>\n"+
+		"</head>\n<body>\n<h1>%s</h1>\n", html.EscapeString(title),
+		reportStyle, html.EscapeString(title))
+	fmt.Fprintf(&sb, "<p>%d traces, %d aligned, total cost %d</p>\n",
+		len(entries), aligned, cost)
+
+	sb.WriteString("<table class=\"index\">\n<thead><tr><th>trace</th>" +
+		"<th>length</th><th>cost</th><th>status</th></tr></thead>\n<tbody>\n")
+	for _, e := range entries {
+		length, costValue, costText, status := 0, "-1", "", "ok"
+		for _, pair := range e.Alignment.Pairs {
+			if pair.Type == LOG || pair.Type == SYNC {
+				length += 1
+			}
+		}
+		if e.Err != "" {
+			status = e.Err
+		} else {
+			costValue, costText = fmt.Sprint(e.Cost), fmt.Sprint(e.Cost)
+		}
+		fmt.Fprintf(&sb, "<tr><td data-value=\"%d\"><a href=\"#trace-%d\">"+
+			"%d</a></td><td data-value=\"%d\">%d</td>"+
+			"<td data-value=\"%s\">%s</td><td data-value=\"%s\">%s</td>"+
+			"</tr>\n", e.Trace, e.Trace, e.Trace, length, length, costValue,
+			costText, html.EscapeString(status), html.EscapeString(status))
+	}
+	sb.WriteString("</tbody>\n</table>\n")
+	sb.WriteString(legend())
+
+	for _, e := range entries {
+		fmt.Fprintf(&sb, "<h2 id=\"trace-%d\">Trace %d</h2>\n", e.Trace,
+			e.Trace)
+		if e.Err != "" {
+			fmt.Fprintf(&sb, "<p class=\"error\">%s</p>\n",
+				html.EscapeString(e.Err))
+			continue
+		}
+		fmt.Fprintf(&sb, "<p>cost %d</p>\n", e.Cost)
+		sb.WriteString(alignmentTable(e.Alignment))
+		if e.Product != nil {
+			sb.WriteString("<details>\n<summary>synchronous product" +
+				"</summary>\n<div class=\"net\">\n")
+			sb.WriteString(selectedProductSVG(e.Product, e.Alignment,
+				fmt.Sprintf("t%d-", e.Trace)))
+			sb.WriteString("</div>\n</details>\n")
+		}
+	}
+	fmt.Fprintf(&sb, "<script>%s</script>\n</body>\n</html>\n",
+		reportScript)
+	return sb.String()
+}
+
+// writes the report of the optimal alignments of the log on the model
+func WriteHTMLReport(modelfn, logfn, outfn string, opts ProductOptions) {
+	CheckError(opts.Silent.Check())
+	model, _ := readModel(modelfn, opts.Silent)
+	logtraces := readLog(logfn)
+	labels := NewLabelMap(&model, opts.LabelFile, opts.Normalize)
+	var entries []reportEntry
+	for _, ta := range model.AlignTraces(logtraces, labels, opts) {
+		e := reportEntry{Trace: ta.Trace, Cost: ta.Cost,
+			Alignment: ta.Alignment}
+		if ta.Err != nil {
+			e.Err = ta.Err.Error()
+		} else {
+			product := ta.Product
+			e.Product = &product
+		}
+		entries = append(entries, e)
+	}
+	WriteFile(outfn, HTMLReport(filepath.Base(modelfn)+" / "+
+		filepath.Base(logfn), entries))
+}
+
+// writes the report of the alignments reconstructed from the solver traces
+// of the products in the directory, as -b does
+func WriteBatchHTMLReport(dir, name, pattern, outfn string) {
+	var entries []reportEntry
+	for _, res := range BatchAlign(dir, name, pattern) {
+		e := reportEntry{Trace: res.Trace, Cost: res.Cost, Err: res.Error}
+		if res.Missing {
+			e.Err = "missing trace"
+		}
+		if res.Alignment != nil {
+			e.Alignment = *res.Alignment
+			var pn PNML
+			err := xml.Unmarshal(readPNML(filepath.Join(dir,
+				fmt.Sprintf(name, res.Trace)+".pnml")), &pn)
+			if err == nil {
+				e.Product = &pn
+			}
+		}
+		entries = append(entries, e)
+	}
+	WriteFile(outfn, HTMLReport(dir, entries))
+}
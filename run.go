@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// End-to-end pipeline: for each log trace, construct the synchronous
+// product, run the external model checker on it and reconstruct the
+// alignment from the trace of the checker.
+//
+// The checker command is run with 'sh -c' after replacing the placeholders
+// {model} (the product PNML), {property} (the invariant or formula file) and
+// {trace} (the trace file the checker should write). The paths are single
+// quoted, so the placeholders should not be quoted in the command.
+
+const DEFAULTCOMMAND string = "pnml2lts-sym {model} --invariant={property}" +
+	" --trace={trace}"
+
+type RunOptions struct {
+	Command  string
+	TraceExt string // extension of the trace written by the command
+	Timeout  time.Duration
+	Jobs     int // number of products checked in parallel
+}
+
+var DefaultRunOptions = RunOptions{Command: DEFAULTCOMMAND, TraceExt: ".gcf",
+	Timeout: 10 * time.Minute, Jobs: 1}
+
+const (
+	RUNALIGNED string = "aligned"
+	RUNNOTRACE string = "no trace"
+	RUNTIMEOUT string = "timeout"
+	RUNFAILED  string = "failed"
+)
+
+type RunResult struct {
+	Trace    int
+	Events   int
+	Status   string
+	Cost     int
+	Duration time.Duration
+	Error    string
+	Align    AlignmentS
+}
+
+func (ropts *RunOptions) command(outdir string, i int,
+	opts ProductOptions) (string, string) {
+	tracefn := filepath.Join(outdir, fmt.Sprintf("trace-%d%s", i,
+		ropts.TraceExt))
+	cmd := strings.NewReplacer(
+		"{model}", shellQuote(filepath.Join(outdir,
+			fmt.Sprintf(opts.Name, i)+".pnml")),
+		"{property}", shellQuote(filepath.Join(outdir,
+			opts.Property.FileName(i))),
+		"{trace}", shellQuote(tracefn)).Replace(ropts.Command)
+	return cmd, tracefn
+}
+
+// quotes s as a single word for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runs the checker on product i, which is already written to outdir
+func (ropts *RunOptions) runChecker(pn *PNML, outdir string, i int,
+	opts ProductOptions) RunResult {
+	res := RunResult{Trace: i}
+	cmd, tracefn := ropts.command(outdir, i, opts)
+	os.RemoveAll(tracefn) // don't use the trace of a previous run
+
+	ctx, cancel := context.WithTimeout(context.Background(), ropts.Timeout)
+	defer cancel()
+	start := time.Now()
+	checker := exec.CommandContext(ctx, "sh", "-c", cmd)
+	checker.WaitDelay = time.Second // children of sh may keep the output open
+	output, err := checker.CombinedOutput()
+	res.Duration = time.Since(start)
+	os.WriteFile(filepath.Join(outdir, fmt.Sprintf("checker-%d.log", i)),
+		output, 0644)
+	if ctx.Err() == context.DeadlineExceeded {
+		res.Status = RUNTIMEOUT
+		return res
+	}
+	if _, serr := os.Stat(tracefn); os.IsNotExist(serr) {
+		res.Status = RUNNOTRACE
+		if err != nil {
+			res.Status = RUNFAILED
+			res.Error = err.Error()
+		}
+		return res
+	}
+	// NB: checkers may exit with a non-zero code when a counter example is
+	// found, so only the trace counts
+	trace, err := ReadTrace(tracefn)
+	if err == nil {
+		res.Align, err = pn.ReconstructAlignment(trace)
+	}
+	if err != nil {
+		res.Status = RUNFAILED
+		res.Error = err.Error()
+		return res
+	}
+	res.Status = RUNALIGNED
+	res.Cost = res.Align.Cost()
+	return res
+}
+
+func RunPipeline(modelfn, logfn, outdir string, opts ProductOptions,
+	ropts RunOptions) []RunResult {
+	hasPNML := false
+	for _, format := range opts.Formats {
+		hasPNML = hasPNML || format == FMTPNML
+	}
+	if !hasPNML { // the checker needs the PNML
+		opts.Formats = append(opts.Formats, FMTPNML)
+	}
+	if ropts.Jobs < 1 {
+		ropts.Jobs = 1
+	}
+	model, logtraces, labels := prepareProducts(modelfn, logfn, outdir, opts)
+
+	results := make([]RunResult, len(logtraces))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < ropts.Jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				pn := model.CreateProduct(logtraces[i], labels, opts)
+				pn.writeProduct(outdir, i, opts)
+				results[i] = ropts.runChecker(&pn, outdir, i, opts)
+				results[i].Events = len(logtraces[i])
+			}
+		}()
+	}
+	for i, _ := range logtraces {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func runTable(results []RunResult) string {
+	ret := fmt.Sprintf("%6s %7s %-9s %5s %10s  %s\n", "trace", "events",
+		"status", "cost", "time", "error")
+	for _, res := range results {
+		cost := "-"
+		if res.Status == RUNALIGNED {
+			cost = fmt.Sprint(res.Cost)
+		}
+		ret += fmt.Sprintf("%6d %7d %-9s %5s %10s  %s\n", res.Trace,
+			res.Events, res.Status, cost,
+			res.Duration.Round(time.Millisecond), res.Error)
+	}
+	return ret
+}
+
+// runs the pipeline, prints a table of the results and writes the
+// alignments to 'alignments.txt' and the results to 'results.csv'
+func RunAlignments(modelfn, logfn, outdir string, opts ProductOptions,
+	ropts RunOptions) {
+	results := RunPipeline(modelfn, logfn, outdir, opts, ropts)
+	aligns := ""
+	csv := "trace,events,status,cost,seconds\n"
+	for _, res := range results {
+		if res.Status == RUNALIGNED {
+			aligns += fmt.Sprintf("trace %d (cost %d)\n%s\n", res.Trace,
+				res.Cost, res.Align.toString())
+		}
+		csv += fmt.Sprintf("%d,%d,%s,%d,%.3f\n", res.Trace, res.Events,
+			res.Status, res.Cost, res.Duration.Seconds())
+	}
+	WriteFile(filepath.Join(outdir, "alignments.txt"), aligns)
+	WriteFile(filepath.Join(outdir, "results.csv"), csv)
+	fmt.Print(runTable(results))
+}
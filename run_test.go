@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Tests of the pipeline with a stub checker, which copies the recorded solver
+// trace of the small product to {trace}. The output directory of a case is
+// created below a temporary directory.
+
+func TestRunAlignments(t *testing.T) {
+	trace, err := filepath.Abs("testdata/small-trace.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden, err := os.ReadFile("testdata/golden/small-alignment.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := "cp '" + trace + "' {trace}"
+	cases := []struct {
+		name    string
+		outdir  string
+		command string
+		timeout time.Duration
+		status  string
+		cost    string
+		aligns  string
+	}{
+		{"aligned", "out", stub, time.Minute, RUNALIGNED, "1",
+			"trace 0 (cost 1)\n" + string(golden) + "\n"},
+		// the placeholders are quoted
+		{"quoted paths", "it's an out dir", stub, time.Minute, RUNALIGNED,
+			"1", "trace 0 (cost 1)\n" + string(golden) + "\n"},
+		// checkers may exit with a non-zero status after writing a trace
+		{"counter example", "out", stub + "; exit 1", time.Minute,
+			RUNALIGNED, "1", "trace 0 (cost 1)\n" + string(golden) + "\n"},
+		{"exit status", "out", "exit 3", time.Minute, RUNFAILED, "0", ""},
+		{"no trace", "out", "true", time.Minute, RUNNOTRACE, "0", ""},
+		{"timeout", "out", "sleep 10; " + stub, 100 * time.Millisecond,
+			RUNTIMEOUT, "0", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), c.outdir)
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			logfn := filepath.Join(dir, "log.csv")
+			WriteFile(logfn, "a,x,b\n")
+			opts := DefaultProductOptions
+			opts.Drawings = nil
+			ropts := RunOptions{Command: c.command, TraceExt: ".txt",
+				Timeout: c.timeout, Jobs: 1}
+			RunAlignments("testdata/small.pnml", logfn, dir, opts, ropts)
+
+			f, err := os.Open(filepath.Join(dir, "results.csv"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			records, err := csv.NewReader(f).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 2 || !reflect.DeepEqual(records[0], []string{
+				"trace", "events", "status", "cost", "seconds"}) {
+				t.Fatalf("unexpected results %v", records)
+			}
+			// the duration varies between runs
+			if got, want := records[1][:4], []string{"0", "3", c.status,
+				c.cost}; !reflect.DeepEqual(got, want) {
+				t.Errorf("expected result %v, got %v", want, got)
+			}
+			aligns, err := os.ReadFile(filepath.Join(dir, "alignments.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(aligns) != c.aligns {
+				t.Errorf("unexpected alignments:\n%s", aligns)
+			}
+		})
+	}
+}
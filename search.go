@@ -0,0 +1,354 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Native search for optimal alignments on the synchronous product, by an
+// explicit state space exploration. Log and model moves cost 1, sync and tau
+// moves are free.
+
+// limit on the number of explored markings
+const MAXSTATES int = 1000000
+
+func moveCost(movetype string) int {
+	if movetype == LOG || movetype == MODEL {
+		return 1
+	}
+	return 0
+}
+
+type searchEdge struct {
+	trans  int // index in TransArr
+	target int // state index
+}
+
+type searchSpace struct {
+	pn      *PNML
+	tarr    TransArr
+	states  [][]int        // markings
+	index   map[string]int // marking key -> state index
+	dist    []int          // distance from the initial marking
+	back    []int          // distance to a final marking
+	edges   [][]searchEdge // outgoing edges of expanded states
+	final   map[int]int    // place index -> tokens in the final marking
+	optimal int            // optimal cost
+}
+
+func markingKey(m []int) string {
+	var sb strings.Builder
+	for _, n := range m {
+		sb.WriteString(strconv.Itoa(n))
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
+
+func (ss *searchSpace) isFinal(s int) bool {
+	for p, n := range ss.final {
+		if ss.states[s][p] != n {
+			return false
+		}
+	}
+	return true
+}
+
+func (ss *searchSpace) state(m []int) int {
+	key := markingKey(m)
+	if s, ok := ss.index[key]; ok {
+		return s
+	}
+	ss.index[key] = len(ss.states)
+	ss.states = append(ss.states, m)
+	ss.dist = append(ss.dist, -1)
+	ss.edges = append(ss.edges, nil)
+	return len(ss.states) - 1
+}
+
+// returns the marking after firing the transition, or nil if not enabled
+func fireVector(trans Trans, m []int) []int {
+	ret := make([]int, len(m))
+	copy(ret, m)
+	for _, in := range trans.In {
+		ret[in] -= 1
+		if ret[in] < 0 {
+			return nil
+		}
+	}
+	for _, out := range trans.Out {
+		ret[out] += 1
+	}
+	return ret
+}
+
+// priority queue of (state, distance)
+type searchItem struct {
+	state int
+	dist  int
+}
+type searchQueue []searchItem
+
+func (q searchQueue) Len() int            { return len(q) }
+func (q searchQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q searchQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *searchQueue) Push(x interface{}) { *q = append(*q, x.(searchItem)) }
+func (q *searchQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// explores all markings with a distance of at most the optimal cost
+func (pn *PNML) exploreProduct() (*searchSpace, error) {
+	placeMap := make(map[string]int)
+	initial := make([]int, len(pn.Net.Page.Places))
+	for i, place := range pn.Net.Page.Places {
+		placeMap[place.ID] = i
+		initial[i], _ = strconv.Atoi(place.InitialMarking)
+	}
+	ss := &searchSpace{pn: pn, tarr: pn.MakeTransArr(placeMap),
+		index: make(map[string]int), final: make(map[int]int), optimal: -1}
+	for _, mp := range pn.Net.FinalMarking.MPlaces {
+		if p, ok := placeMap[mp.ID]; ok {
+			ss.final[p], _ = strconv.Atoi(mp.TokenCount)
+		}
+	}
+	s0 := ss.state(initial)
+	ss.dist[s0] = 0
+	q := &searchQueue{{state: s0, dist: 0}}
+	expanded := make(map[int]bool)
+	for q.Len() > 0 {
+		item := heap.Pop(q).(searchItem)
+		if expanded[item.state] || item.dist > ss.dist[item.state] {
+			continue
+		}
+		if ss.optimal != -1 && item.dist > ss.optimal {
+			break
+		}
+		expanded[item.state] = true
+		if ss.isFinal(item.state) && ss.optimal == -1 {
+			ss.optimal = item.dist
+		}
+		for ti, trans := range ss.tarr.Trans {
+			m := fireVector(trans, ss.states[item.state])
+			if m == nil {
+				continue
+			}
+			t := ss.state(m)
+			if len(ss.states) > MAXSTATES {
+				return ss, fmt.Errorf("Exceeded %d markings", MAXSTATES)
+			}
+			ss.edges[item.state] = append(ss.edges[item.state],
+				searchEdge{trans: ti, target: t})
+			d := item.dist + moveCost(trans.Type)
+			if ss.dist[t] == -1 || d < ss.dist[t] {
+				ss.dist[t] = d
+				heap.Push(q, searchItem{state: t, dist: d})
+			}
+		}
+	}
+	if ss.optimal == -1 {
+		return ss, errors.New("The final marking is not reachable")
+	}
+	ss.computeBack(expanded)
+	return ss, nil
+}
+
+// computes the distances to the final markings over the expanded states
+func (ss *searchSpace) computeBack(expanded map[int]bool) {
+	ss.back = make([]int, len(ss.states))
+	reverse := make([][]searchEdge, len(ss.states))
+	q := &searchQueue{}
+	for s, _ := range ss.states {
+		ss.back[s] = -1
+		if expanded[s] && ss.isFinal(s) {
+			ss.back[s] = 0
+			heap.Push(q, searchItem{state: s, dist: 0})
+		}
+		for _, e := range ss.edges[s] {
+			reverse[e.target] = append(reverse[e.target],
+				searchEdge{trans: e.trans, target: s})
+		}
+	}
+	for q.Len() > 0 {
+		item := heap.Pop(q).(searchItem)
+		if item.dist > ss.back[item.state] {
+			continue
+		}
+		for _, e := range reverse[item.state] {
+			d := item.dist + moveCost(ss.tarr.Trans[e.trans].Type)
+			if ss.back[e.target] == -1 || d < ss.back[e.target] {
+				ss.back[e.target] = d
+				heap.Push(q, searchItem{state: e.target, dist: d})
+			}
+		}
+	}
+}
+
+// enumerates the optimal alignments, without repeating markings, up to limit
+// alignments (0 for all)
+func (ss *searchSpace) enumerate(limit int) []AlignmentS {
+	var ret []AlignmentS
+	onPath := make(map[int]bool)
+	var path []int // transition indices
+	var dfs func(s int) bool
+	dfs = func(s int) bool {
+		if ss.back[s] == 0 && ss.isFinal(s) {
+			var al AlignmentS
+			for _, ti := range path {
+				al.AddPair(ss.pn, ss.tarr.Trans[ti].T)
+			}
+			ret = append(ret, al)
+			return limit > 0 && len(ret) >= limit
+		}
+		onPath[s] = true
+		defer delete(onPath, s)
+		for _, e := range ss.edges[s] {
+			w := moveCost(ss.tarr.Trans[e.trans].Type)
+			if onPath[e.target] || ss.back[e.target] == -1 ||
+				w+ss.back[e.target] != ss.back[s] {
+				continue
+			}
+			path = append(path, e.trans)
+			done := dfs(e.target)
+			path = path[:len(path)-1]
+			if done {
+				return true
+			}
+		}
+		return false
+	}
+	dfs(0)
+	return ret
+}
+
+// Returns the optimal cost and up to limit optimal alignments (0 for all) of
+// the synchronous product
+func (pn *PNML) OptimalAlignments(limit int) (int, []AlignmentS, error) {
+	ss, err := pn.exploreProduct()
+	if err != nil {
+		return -1, nil, err
+	}
+	return ss.optimal, ss.enumerate(limit), nil
+}
+
+// the multiset of model moves, as sorted labels
+func (al *AlignmentS) modelMoves() string {
+	var moves []string
+	for _, pair := range al.Pairs {
+		if pair.Type == MODEL {
+			moves = append(moves, pair.Trans)
+		}
+	}
+	sort.Strings(moves)
+	return "{" + strings.Join(moves, ", ") + "}"
+}
+
+// groups the alignments by their multiset of model moves
+func GroupAlignments(als []AlignmentS) ([]string, map[string][]AlignmentS) {
+	var keys []string
+	groups := make(map[string][]AlignmentS)
+	for _, al := range als {
+		key := al.modelMoves()
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], al)
+	}
+	return keys, groups
+}
+
+// prints the optimal alignments of each log trace, grouped by model moves
+func EnumerateAlignments(modelfn, logfn string, opts ProductOptions,
+	limit int) {
+	CheckError(opts.Silent.Check())
+	model, _ := readModel(modelfn, opts.Silent)
+	logtraces := readLog(logfn)
+	labels := NewLabelMap(&model, opts.LabelFile, opts.Normalize)
+	for i, logtrace := range logtraces {
+		pn := model.CreateProduct(logtrace, labels, opts)
+		cost, als, err := pn.OptimalAlignments(limit)
+		if err != nil {
+			fmt.Printf("trace %d: %v\n\n", i, err)
+			continue
+		}
+		keys, groups := GroupAlignments(als)
+		atLeast := ""
+		if limit > 0 && len(als) >= limit {
+			atLeast = "at least "
+		}
+		fmt.Printf("trace %d: cost %d, %s%d optimal alignments in %d"+
+			" groups\n", i, cost, atLeast, len(als), len(keys))
+		for g, key := range keys {
+			fmt.Printf("group %d: model moves %s, %d alignments\n", g, key,
+				len(groups[key]))
+			for _, al := range groups[key] {
+				fmt.Println(al.toString())
+			}
+		}
+	}
+}
+
+type TraceAlignment struct {
+	Trace     int
+	Product   PNML
+	Cost      int
+	Alignment AlignmentS
+	Err       error
+}
+
+// computes an optimal alignment of each log trace on the model
+func (pn *PNML) AlignTraces(logtraces [][]string, labels *LabelMap,
+	opts ProductOptions) []TraceAlignment {
+	var ret []TraceAlignment
+	for i, logtrace := range logtraces {
+		ta := TraceAlignment{Trace: i,
+			Product: pn.CreateProduct(logtrace, labels, opts)}
+		cost, als, err := ta.Product.OptimalAlignments(1)
+		ta.Cost = cost
+		ta.Err = err
+		if err == nil {
+			ta.Alignment = als[0]
+		}
+		ret = append(ret, ta)
+	}
+	return ret
+}
+
+// returns the ID of the model transition of the pair, "" for log moves
+func (ta *TraceAlignment) modelTransID(pair AlignPair) string {
+	switch pair.Type {
+	case LOG:
+		return ""
+	case SYNC:
+		for _, trans := range ta.Product.Net.Page.Transitions {
+			if trans.ID == pair.TransID {
+				return trans.Model
+			}
+		}
+		return ""
+	}
+	return pair.TransID
+}
+
+// replays the alignment on the product, calling visit with each pair and the
+// product marking after the pair
+func (ta *TraceAlignment) Replay(visit func(pair AlignPair, m MGMarking)) {
+	prod := &ta.Product
+	m := prod.InitialMGMarking()
+	for _, pair := range ta.Alignment.Pairs {
+		for _, trans := range prod.Net.Page.Transitions {
+			if trans.ID == pair.TransID {
+				m = prod.Fire(trans, m)
+				break
+			}
+		}
+		visit(pair, m)
+	}
+}
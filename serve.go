@@ -0,0 +1,516 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// HTTP interface, running on a single host without external services. Models,
+// logs and solver traces are uploaded as the request body (or as the "file"
+// of a multipart form) and stored in a work directory; products and
+// alignments are computed by jobs on a bounded queue.
+//
+//   POST /models?name=FILE.{pnml,bpmn,ptml}   -> {"id": ...}
+//   POST /logs?name=FILE.{csv,xes}            -> {"id": ...}
+//   POST /labels?name=FILE.csv                -> {"id": ...}, see -labels
+//   POST /products  {"model", "log", "options"}  -> job, as -p
+//   POST /align     {"model", "log", "options"}  -> job, native solver
+//   POST /jobs/ID/traces/I?name=FILE.{txt,csv,gcf,dir,json}
+//                                             -> solver trace of product I
+//   POST /jobs/ID/reconstruct                 -> job, as -b
+//   GET  /jobs/ID                             -> job status and result
+//   GET  /jobs/ID/files/NAME                  -> file written by the job
+
+type ServeOptions struct {
+	Addr      string
+	MaxUpload int64 // bytes per request
+	Jobs      int   // parallel jobs
+	Queue     int   // queued jobs
+	Dir       string
+}
+
+var DefaultServeOptions = ServeOptions{Addr: "localhost:8080",
+	MaxUpload: 64 << 20, Jobs: 1, Queue: 100}
+
+// job states
+const (
+	JOBQUEUED  string = "queued"
+	JOBRUNNING string = "running"
+	JOBDONE    string = "done"
+	JOBFAILED  string = "failed"
+)
+
+// the product options of a request, see parseProductOptions
+type serveProductOptions struct {
+	Prop    string   `json:"prop"`
+	K       *int     `json:"k"`
+	Formats []string `json:"formats"`
+	Labels  string   `json:"labels"` // ID of an uploaded label mapping
+	Match   string   `json:"match"`
+	MaxSize int      `json:"maxsize"`
+	Final   string   `json:"final"`
+}
+
+type serveRequest struct {
+	Model   string              `json:"model"`
+	Log     string              `json:"log"`
+	Options serveProductOptions `json:"options"`
+}
+
+type serveJob struct {
+	ID     string      `json:"id"`
+	Kind   string      `json:"kind"`
+	Status string      `json:"status"`
+	Error  string      `json:"error,omitempty"`
+	Result interface{} `json:"result,omitempty"`
+	dir    string
+	run    func(j *serveJob) (interface{}, error)
+}
+
+type server struct {
+	opts   ServeOptions
+	mu     sync.Mutex
+	nextID int
+	files  map[string]string // upload ID -> file
+	jobs   map[string]*serveJob
+	queue  chan *serveJob
+}
+
+func newServer(opts ServeOptions) *server {
+	s := &server{opts: opts, files: make(map[string]string),
+		jobs:  make(map[string]*serveJob),
+		queue: make(chan *serveJob, opts.Queue)}
+	for i := 0; i < opts.Jobs; i++ {
+		go s.worker()
+	}
+	return s
+}
+
+func (s *server) newID(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID += 1
+	return fmt.Sprintf("%s%d", prefix, s.nextID)
+}
+
+// runs the jobs of the queue, failures of CheckError fail the job
+func (s *server) worker() {
+	for j := range s.queue {
+		s.setJob(j, JOBRUNNING, nil, nil)
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					s.setJob(j, JOBFAILED, nil, fmt.Errorf("%v", r))
+				}
+			}()
+			result, err := j.run(j)
+			if err != nil {
+				s.setJob(j, JOBFAILED, nil, err)
+			} else {
+				s.setJob(j, JOBDONE, result, nil)
+			}
+		}()
+	}
+}
+
+func (s *server) setJob(j *serveJob, status string, result interface{},
+	err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.Status = status
+	j.Result = result
+	if err != nil {
+		j.Error = err.Error()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	output, err := json.MarshalIndent(v, "", "  ")
+	CheckError(err)
+	w.Write(append(output, '\n'))
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// returns an error if the file cannot be read as a model. BPMN models and
+// process trees are only parsed, they are imported by the job.
+func checkModelUpload(fn string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	switch {
+	case strings.HasSuffix(fn, ".bpmn"):
+		parseBPMN(fn)
+		return nil
+	case strings.HasSuffix(fn, ".ptml"):
+		parsePTML(fn)
+		return nil
+	}
+	pn, _ := readModel(fn, DefaultSilentOptions)
+	if len(pn.Net.Page.Places) == 0 {
+		return errors.New("The model has no places")
+	}
+	return nil
+}
+
+// returns an error if the file cannot be read as a log
+func checkLogUpload(fn string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	readLog(fn)
+	return nil
+}
+
+// stores the body (or multipart file) of the request as dir/base+ext
+func (s *server) saveUpload(w http.ResponseWriter, r *http.Request, dir,
+	base string, exts []string) (string, error) {
+	name := r.URL.Query().Get("name")
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		body = file
+		if name == "" {
+			name = header.Filename
+		}
+	}
+	ext := filepath.Ext(name)
+	known := false
+	for _, e := range exts {
+		known = known || e == ext
+	}
+	if !known {
+		return "", fmt.Errorf("Unknown file extension '%s' of '%s', expected"+
+			" one of %s", ext, name, strings.Join(exts, ", "))
+	}
+	fn := filepath.Join(dir, base+ext)
+	file, err := os.Create(fn)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, body); err != nil {
+		os.Remove(fn)
+		return "", err
+	}
+	return fn, nil
+}
+
+// handles uploads of files with the extensions, which should pass check if
+// it isn't nil
+func (s *server) handleUpload(prefix string, exts []string,
+	check func(fn string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed,
+				errors.New("Expected POST"))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxUpload)
+		id := s.newID(prefix)
+		fn, err := s.saveUpload(w, r, s.opts.Dir, id, exts)
+		if err == nil && check != nil {
+			if err = check(fn); err != nil {
+				os.Remove(fn)
+			}
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.mu.Lock()
+		s.files[id] = fn
+		s.mu.Unlock()
+		writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+	}
+}
+
+func (s *server) file(id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn, ok := s.files[id]
+	if !ok {
+		return "", fmt.Errorf("Unknown upload '%s'", id)
+	}
+	return fn, nil
+}
+
+// returns the product options of the request
+func (s *server) productOptions(o serveProductOptions) (ProductOptions,
+	error) {
+	opts := DefaultProductOptions
+	if o.Prop != "" {
+		opts.Property.Format = o.Prop
+	}
+	if o.K != nil {
+		opts.Property.CostBound = *o.K
+	}
+	if len(o.Formats) > 0 {
+		opts.Formats = o.Formats
+	}
+	if o.Labels != "" {
+		fn, err := s.file(o.Labels)
+		if err != nil {
+			return opts, err
+		}
+		opts.LabelFile = fn
+	}
+	if o.Match != "" && o.Match != "exact" && o.Match != "normalized" {
+		return opts, errors.New("Unknown matching: '" + o.Match + "'")
+	}
+	opts.Normalize = o.Match == "normalized"
+	if o.Final != "" && o.Final != "full" && o.Final != "prefix" {
+		return opts, errors.New("Unknown final marking: '" + o.Final + "'")
+	}
+	opts.Prefix = o.Final == "prefix"
+	opts.MaxSize = o.MaxSize
+	// products are only drawn on request (see GET /jobs/ID/files)
+	opts.Drawings = nil
+	if err := opts.Property.Check(); err != nil {
+		return opts, err
+	}
+	for _, format := range opts.Formats {
+		if err := CheckFormat(format); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// queues the job, or fails with 503 if the queue is full
+func (s *server) submit(w http.ResponseWriter, j *serveJob) {
+	j.Status = JOBQUEUED
+	s.mu.Lock()
+	s.jobs[j.ID] = j
+	status := *j // the worker may update the job once it is queued
+	s.mu.Unlock()
+	select {
+	case s.queue <- j:
+		writeJSON(w, http.StatusAccepted, status)
+	default:
+		s.mu.Lock()
+		delete(s.jobs, j.ID)
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable,
+			errors.New("The job queue is full"))
+	}
+}
+
+// handles POST /products and POST /align
+func (s *server) handleModelLog(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed,
+				errors.New("Expected POST"))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxUpload)
+		var req serveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		modelfn, err := s.file(req.Model)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		logfn, err := s.file(req.Log)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		opts, err := s.productOptions(req.Options)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		j := &serveJob{ID: s.newID("j"), Kind: kind}
+		j.dir = filepath.Join(s.opts.Dir, j.ID)
+		if err := os.Mkdir(j.dir, 0755); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if kind == "products" {
+			j.run = func(j *serveJob) (interface{}, error) {
+				CreatePNMLProduct(modelfn, logfn, j.dir, opts)
+				return jobFiles(j.dir)
+			}
+		} else {
+			j.run = func(j *serveJob) (interface{}, error) {
+				return nativeAlign(modelfn, logfn, opts), nil
+			}
+		}
+		s.submit(w, j)
+	}
+}
+
+// returns the names of the files written by the job
+func jobFiles(dir string) (interface{}, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	sort.Strings(names)
+	return map[string][]string{"files": names}, nil
+}
+
+// computes the optimal alignments of the log on the model
+func nativeAlign(modelfn, logfn string, opts ProductOptions) []BatchResult {
+	CheckError(opts.Silent.Check())
+	model, _ := readModel(modelfn, opts.Silent)
+	logtraces := readLog(logfn)
+	labels := NewLabelMap(&model, opts.LabelFile, opts.Normalize)
+	var ret []BatchResult
+	for _, ta := range model.AlignTraces(logtraces, labels, opts) {
+		res := BatchResult{Trace: ta.Trace, Cost: ta.Cost}
+		if ta.Err != nil {
+			res.Error = ta.Err.Error()
+		} else {
+			al := ta.Alignment
+			res.Alignment = &al
+		}
+		ret = append(ret, res)
+	}
+	return ret
+}
+
+// handles /jobs/ID, /jobs/ID/files/NAME, /jobs/ID/traces/I and
+// /jobs/ID/reconstruct
+func (s *server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path,
+		"/jobs/"), "/"), "/")
+	s.mu.Lock()
+	j, ok := s.jobs[parts[0]]
+	var status serveJob
+	if ok {
+		status = *j
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound,
+			fmt.Errorf("Unknown job '%s'", parts[0]))
+		return
+	}
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, &status)
+	case len(parts) == 3 && parts[1] == "files" &&
+		r.Method == http.MethodGet:
+		if parts[2] != filepath.Base(parts[2]) || parts[2] == ".." {
+			writeError(w, http.StatusBadRequest, errors.New("Invalid name"))
+			return
+		}
+		fn := filepath.Join(j.dir, parts[2])
+		if _, err := os.Stat(fn); err != nil {
+			// drawings are written on request
+			base := strings.TrimSuffix(fn, filepath.Ext(fn))
+			drawing := strings.TrimPrefix(filepath.Ext(fn), ".")
+			if CheckDrawing(drawing) != nil {
+				writeError(w, http.StatusNotFound, err)
+				return
+			}
+			var pn PNML
+			if err := s.readProduct(base+".pnml", &pn); err != nil {
+				writeError(w, http.StatusNotFound, err)
+				return
+			}
+			pn.writeDrawings(base, []string{drawing})
+		}
+		http.ServeFile(w, r, fn)
+	case len(parts) == 3 && parts[1] == "traces" &&
+		r.Method == http.MethodPost && status.Kind == "products":
+		i, err := strconv.Atoi(parts[2])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxUpload)
+		fn, err := s.saveUpload(w, r, j.dir,
+			fmt.Sprintf(DEFAULTTRACEPATTERN, i), TRACEEXTS)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated,
+			map[string]string{"trace": filepath.Base(fn)})
+	case len(parts) == 2 && parts[1] == "reconstruct" &&
+		r.Method == http.MethodPost && status.Kind == "products":
+		if status.Status != JOBDONE {
+			writeError(w, http.StatusConflict,
+				fmt.Errorf("The job '%s' is %s", j.ID, status.Status))
+			return
+		}
+		rj := &serveJob{ID: s.newID("j"), Kind: "reconstruct", dir: j.dir}
+		rj.run = func(rj *serveJob) (interface{}, error) {
+			return BatchAlign(rj.dir, DEFAULTPRODUCTNAME,
+				DEFAULTTRACEPATTERN), nil
+		}
+		s.submit(w, rj)
+	default:
+		writeError(w, http.StatusNotFound,
+			fmt.Errorf("Unknown request %s %s", r.Method, r.URL.Path))
+	}
+}
+
+func (s *server) readProduct(fn string, pn *PNML) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	if _, err := os.Stat(fn); err != nil {
+		return err
+	}
+	*pn = readNet(fn)
+	return nil
+}
+
+// Serves the HTTP interface until it fails, the temporary work directory is
+// removed before the error is returned
+func Serve(opts ServeOptions) error {
+	if opts.Dir == "" {
+		dir, err := os.MkdirTemp("", "pnmlprod-serve")
+		CheckError(err)
+		defer os.RemoveAll(dir)
+		opts.Dir = dir
+	}
+	s := newServer(opts)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/models", s.handleUpload("m",
+		[]string{".pnml", ".bpmn", ".ptml"}, checkModelUpload))
+	mux.HandleFunc("/logs", s.handleUpload("l",
+		[]string{".csv", ".xes"}, checkLogUpload))
+	mux.HandleFunc("/labels", s.handleUpload("f", []string{".csv"}, nil))
+	mux.HandleFunc("/products", s.handleModelLog("products"))
+	mux.HandleFunc("/align", s.handleModelLog("align"))
+	mux.HandleFunc("/jobs/", s.handleJobs)
+	fmt.Printf("Serving on http://%s, work directory %s\n", opts.Addr,
+		opts.Dir)
+	return http.ListenAndServe(opts.Addr, mux)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Detection of silent (invisible) transitions in the model, which become TAU
+// transitions in the product.
+
+const (
+	SILENTPROM  string = "prom"  // ProM's activity="$invisible$" marker
+	SILENTEMPTY string = "empty" // transitions with an empty label
+	SILENTREGEX string = "regex" // labels matching a regular expression
+	SILENTLIST  string = "list"  // IDs or labels listed in a file
+
+	PROMINVISIBLE string = "$invisible$"
+)
+
+type SilentOptions struct {
+	Detect []string // enabled detection methods
+	Regex  *regexp.Regexp
+	List   map[string]bool // transition IDs and labels
+}
+
+var DefaultSilentOptions = SilentOptions{
+	Detect: []string{SILENTPROM, SILENTEMPTY, SILENTREGEX},
+	Regex:  regexp.MustCompile(`^(tau|τ)(\s|$)`)}
+
+type SilentTransition struct {
+	ID     string
+	Name   string
+	Reason string
+}
+
+func (opts *SilentOptions) enabled(method string) bool {
+	for _, m := range opts.Detect {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (opts *SilentOptions) Check() error {
+	for _, m := range opts.Detect {
+		if m != SILENTPROM && m != SILENTEMPTY && m != SILENTREGEX &&
+			m != SILENTLIST {
+			return errors.New("Unknown silent transition detection '" + m +
+				"'")
+		}
+	}
+	if opts.enabled(SILENTREGEX) && opts.Regex == nil {
+		return errors.New("No regular expression given for silent" +
+			" transitions")
+	}
+	return nil
+}
+
+// reads a file with one transition ID or label per line
+func ReadSilentList(filename string) map[string]bool {
+	file, err := os.Open(filename)
+	CheckError(err)
+	defer file.Close()
+	ret := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			ret[line] = true
+		}
+	}
+	CheckError(scanner.Err())
+	return ret
+}
+
+// returns the reason why the transition is silent, or "" if it is visible
+func (opts *SilentOptions) silentReason(trans Transition) string {
+	if opts.enabled(SILENTPROM) {
+		for _, ts := range trans.ToolSpecific {
+			if ts.Activity == PROMINVISIBLE {
+				return SILENTPROM
+			}
+		}
+	}
+	if opts.enabled(SILENTEMPTY) && strings.TrimSpace(trans.Name) == "" {
+		return SILENTEMPTY
+	}
+	if opts.enabled(SILENTREGEX) && opts.Regex.MatchString(trans.Name) {
+		return SILENTREGEX
+	}
+	if opts.enabled(SILENTLIST) && (opts.List[trans.ID] ||
+		opts.List[trans.Name]) {
+		return SILENTLIST
+	}
+	return ""
+}
+
+func SilentReport(silent []SilentTransition) string {
+	ret := ""
+	for _, st := range silent {
+		ret += fmt.Sprintf("%s,%s,%s\n", st.ID, st.Name, st.Reason)
+	}
+	return ret
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Readers for the traces of the model checker. A trace is a sequence of
+// TraceParts, where the first part has MoveType "INITIAL" and contains the
+// initial marking of all places, and each next part contains the move type
+// and the (changed) place markings after the move.
+//
+// Supported formats, by file extension:
+//  - .txt (or any other): text output of ltsmin-printtrace, see readTextTrace
+//  - .csv: CSV output of ltsmin-printtrace
+//  - .gcf, .dir: LTSmin trace archives, converted with ltsmin-printtrace
+//  - .json: {"initial": {"p": 1}, "steps": [{"action": "SYNC",
+//    "marking": {"p": 0}}]}
+
+const (
+	INITIAL    string = "INITIAL"
+	PRINTTRACE string = "ltsmin-printtrace"
+)
+
+func ReadTrace(tracefn string) ([]TracePart, error) {
+	switch filepath.Ext(filepath.Clean(tracefn)) {
+	case ".csv":
+		return readCSVTrace(tracefn)
+	case ".gcf", ".dir":
+		return readLTSminTrace(tracefn)
+	case ".json":
+		return readJSONTrace(tracefn)
+	default:
+		return readTextTrace(tracefn)
+	}
+}
+
+func traceError(fn string, line int, format string,
+	args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", fn, line, fmt.Sprintf(format, args...))
+}
+
+// returns the move type of an action label, quotes are ignored
+func parseMoveType(label string) (string, bool) {
+	for _, movetype := range MOVES {
+		if strings.Contains(label, movetype) {
+			return movetype, true
+		}
+	}
+	return "", false
+}
+
+// The text format is line based:
+//
+//	line   := ""  | "#" comment | "state" ... | action | marking
+//	action := "action" ... MOVE ...     (MOVE is one of LOG, MODEL, SYNC, TAU)
+//	marking:= ... PLACE[:TYPE] "=" COUNT
+//
+// each action starts a new trace part, markings are added to the current part
+func readTextTrace(tracefn string) ([]TracePart, error) {
+	file, err := os.Open(tracefn)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var trace []TracePart
+	currentTP := TracePart{MoveType: INITIAL}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), MAXLINE)
+	lineno := 0
+	for scanner.Scan() {
+		lineno += 1
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case fields[0] == "state":
+			continue
+		case fields[0] == "action":
+			movetype, ok := parseMoveType(line)
+			if !ok {
+				return nil, traceError(tracefn, lineno, "action without move"+
+					" type (one of %s): '%s'", strings.Join(MOVES, ", "), line)
+			}
+			trace = append(trace, currentTP)
+			currentTP = TracePart{MoveType: movetype}
+		case strings.Contains(line, "="):
+			split := strings.SplitN(line, "=", 2)
+			lhs := strings.Fields(split[0])
+			if len(lhs) == 0 {
+				return nil, traceError(tracefn, lineno, "missing place: '%s'",
+					line)
+			}
+			placeID := strings.Split(lhs[len(lhs)-1], ":")[0]
+			tokencount, err := strconv.Atoi(strings.TrimSpace(split[1]))
+			if err != nil {
+				return nil, traceError(tracefn, lineno, "invalid token count"+
+					": '%s'", line)
+			}
+			currentTP.PlaceIDs = append(currentTP.PlaceIDs, placeID)
+			currentTP.PlaceTokens = append(currentTP.PlaceTokens, tokencount)
+		default:
+			return nil, traceError(tracefn, lineno, "unable to parse line: '%s'",
+				line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	trace = append(trace, currentTP)
+	return trace, checkTrace(tracefn, trace)
+}
+
+// The CSV output of ltsmin-printtrace has a header with the state variable
+// names ("place" or "place:type"), the action column is the label of the
+// edge from the state in that row to the state in the next row
+func readCSVTrace(tracefn string) ([]TracePart, error) {
+	file, err := os.Open(tracefn)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", tracefn, err)
+	}
+	if len(records) < 2 {
+		return nil, traceError(tracefn, 1, "expected a header and a state")
+	}
+	header := records[0]
+	actionCol := -1
+	var places []string
+	for i, name := range header {
+		split := strings.Split(strings.TrimSpace(name), ":")
+		if split[0] == "action" ||
+			(len(split) > 1 && split[len(split)-1] == "action") {
+			actionCol = i
+		}
+		places = append(places, split[0])
+	}
+	if actionCol == -1 {
+		return nil, traceError(tracefn, 1, "no action column in header")
+	}
+	var trace []TracePart
+	movetype := INITIAL
+	for r, record := range records[1:] {
+		lineno := r + 2
+		if len(record) != len(header) {
+			return nil, traceError(tracefn, lineno, "expected %d columns, got"+
+				" %d", len(header), len(record))
+		}
+		tp := TracePart{MoveType: movetype}
+		for i, value := range record {
+			if i == actionCol {
+				continue
+			}
+			tokencount, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, traceError(tracefn, lineno, "invalid token count"+
+					" '%s' for place %s", value, places[i])
+			}
+			tp.PlaceIDs = append(tp.PlaceIDs, places[i])
+			tp.PlaceTokens = append(tp.PlaceTokens, tokencount)
+		}
+		trace = append(trace, tp)
+		// the action of this row leads to the next state
+		label := strings.TrimSpace(record[actionCol])
+		if r < len(records)-2 {
+			var ok bool
+			movetype, ok = parseMoveType(label)
+			if !ok {
+				return nil, traceError(tracefn, lineno, "action without move"+
+					" type (one of %s): '%s'", strings.Join(MOVES, ", "),
+					label)
+			}
+		}
+	}
+	return trace, checkTrace(tracefn, trace)
+}
+
+// converts the trace archive to CSV with ltsmin-printtrace
+func readLTSminTrace(tracefn string) ([]TracePart, error) {
+	dir, err := os.MkdirTemp("", "pnmlprod")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	csvfn := filepath.Join(dir, "trace.csv")
+	output, err := exec.Command(PRINTTRACE, tracefn, csvfn).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %v\n%s", PRINTTRACE, tracefn,
+			err, output)
+	}
+	return readCSVTrace(csvfn)
+}
+
+type JSONTrace struct {
+	Initial map[string]int `json:"initial"`
+	Steps   []JSONStep     `json:"steps"`
+}
+
+type JSONStep struct {
+	Action  string         `json:"action"`
+	Marking map[string]int `json:"marking"`
+}
+
+func markingToTracePart(movetype string, marking map[string]int) TracePart {
+	tp := TracePart{MoveType: movetype}
+	var places []string
+	for place, _ := range marking {
+		places = append(places, place)
+	}
+	sort.Strings(places)
+	for _, place := range places {
+		tp.PlaceIDs = append(tp.PlaceIDs, place)
+		tp.PlaceTokens = append(tp.PlaceTokens, marking[place])
+	}
+	return tp
+}
+
+func readJSONTrace(tracefn string) ([]TracePart, error) {
+	contents, err := os.ReadFile(tracefn)
+	if err != nil {
+		return nil, err
+	}
+	var jt JSONTrace
+	if err := json.Unmarshal(contents, &jt); err != nil {
+		return nil, fmt.Errorf("%s: %v", tracefn, err)
+	}
+	trace := []TracePart{markingToTracePart(INITIAL, jt.Initial)}
+	for i, step := range jt.Steps {
+		movetype, ok := parseMoveType(step.Action)
+		if !ok {
+			return nil, fmt.Errorf("%s: step %d: action without move type"+
+				" (one of %s): '%s'", tracefn, i, strings.Join(MOVES, ", "),
+				step.Action)
+		}
+		trace = append(trace, markingToTracePart(movetype, step.Marking))
+	}
+	return trace, checkTrace(tracefn, trace)
+}
+
+// the initial marking should contain all places that are changed later on
+func checkTrace(tracefn string, trace []TracePart) error {
+	initial := make(map[string]bool)
+	for _, place := range trace[0].PlaceIDs {
+		initial[place] = true
+	}
+	if len(initial) == 0 {
+		return fmt.Errorf("%s: no initial marking", tracefn)
+	}
+	for i, tp := range trace[1:] {
+		for _, place := range tp.PlaceIDs {
+			if !initial[place] {
+				return fmt.Errorf("%s: step %d: place '%s' is not in the"+
+					" initial marking", tracefn, i+1, place)
+			}
+		}
+	}
+	return nil
+}
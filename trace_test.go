@@ -0,0 +1,31 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// places whose name contains "action" are markings, not actions
+func TestReadTextTraceActionPlace(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "trace.txt")
+	WriteFile(fn, "state 0/1\n"+
+		"\ttransaction:place = 1\n"+
+		"\tdone:place = 0\n"+
+		"action 0/1 \"MODEL\"\n"+
+		"\ttransaction:place = 0\n"+
+		"\tdone:place = 1\n")
+	trace, err := ReadTrace(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TracePart{
+		{MoveType: INITIAL, PlaceIDs: []string{"transaction", "done"},
+			PlaceTokens: []int{1, 0}},
+		{MoveType: MODEL, PlaceIDs: []string{"transaction", "done"},
+			PlaceTokens: []int{0, 1}},
+	}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("expected %v, got %v", want, trace)
+	}
+}
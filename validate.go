@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Validation of an alignment of a log trace on the (original) model: the log
+// projection should equal the trace and the model projection should be a
+// firing sequence from the initial to the final marking.
+
+type alignValidator struct {
+	pn     *PNML
+	pairs  []AlignPair
+	labels *LabelMap
+	final  MGMarking
+	// the deepest failure, which is the most informative one
+	failStep int
+	failMsg  string
+}
+
+func (v *alignValidator) fail(step int, msg string) {
+	if step >= v.failStep {
+		v.failStep = step
+		v.failMsg = msg
+	}
+}
+
+// returns the model transitions the pair can be replayed with: for model and
+// tau moves the transition with the ID (if it exists), otherwise all visible
+// transitions with a matching label
+func (v *alignValidator) candidates(pair AlignPair) []Transition {
+	var ret []Transition
+	if pair.Type == MODEL || pair.Type == TAU {
+		for _, trans := range v.pn.Net.Page.Transitions {
+			if trans.ID == pair.TransID {
+				return []Transition{trans}
+			}
+		}
+	}
+	if pair.Type == TAU {
+		return nil
+	}
+	labels := []string{pair.Trans}
+	if pair.Type == SYNC {
+		labels = append(labels, v.labels.ModelLabels(pair.Log)...)
+	}
+	for _, trans := range v.pn.Net.Page.Transitions {
+		if trans.Type != MODEL {
+			continue
+		}
+		for _, label := range labels {
+			if trans.Name == label {
+				ret = append(ret, trans)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// replays the pairs from step i on marking m, with backtracking over the
+// candidate transitions of duplicate labels
+func (v *alignValidator) replay(i int, m MGMarking) bool {
+	if i == len(v.pairs) {
+		if !markingEquals(m, v.final) {
+			v.fail(i, "the final marking is not reached, the marking is: "+
+				m.Print())
+			return false
+		}
+		return true
+	}
+	pair := v.pairs[i]
+	if pair.Type == LOG {
+		return v.replay(i+1, m)
+	}
+	cands := v.candidates(pair)
+	if len(cands) == 0 {
+		v.fail(i, fmt.Sprintf("step %d %s: no model transition for '%s'", i,
+			pair.toString(), pair.Trans))
+		return false
+	}
+	for _, trans := range cands {
+		if !v.pn.CanFire(trans, m) {
+			v.fail(i, fmt.Sprintf("step %d %s: transition %s is not enabled"+
+				" in marking %s", i, pair.toString(), trans.ID, m.Print()))
+			continue
+		}
+		if v.replay(i+1, v.pn.Fire(trans, m)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks the alignment for the log trace on the post-processed model and
+// returns its cost
+func (pn *PNML) ValidateAlignment(logtrace []string, al AlignmentS,
+	labels *LabelMap) (int, error) {
+	var logProj []string
+	for _, pair := range al.Pairs {
+		if pair.Type == LOG || pair.Type == SYNC {
+			logProj = append(logProj, pair.Log)
+		}
+	}
+	equal := len(logProj) == len(logtrace)
+	for i := 0; equal && i < len(logProj); i++ {
+		equal = logProj[i] == logtrace[i]
+	}
+	if !equal {
+		return 0, fmt.Errorf("The log projection [%s] differs from the log"+
+			" trace [%s]", strings.Join(logProj, ","),
+			strings.Join(logtrace, ","))
+	}
+	v := &alignValidator{pn: pn, pairs: al.Pairs, labels: labels,
+		final: pn.FinalMGMarking(), failStep: -1}
+	if !v.replay(0, pn.InitialMGMarking()) {
+		return 0, errors.New("The model projection is not a firing sequence" +
+			" to the final marking: " + v.failMsg)
+	}
+	return al.Cost(), nil
+}
+
+// validates the alignment of trace index traceidx of the log
+func ValidateAlignmentFile(modelfn, logfn, traceidx, alignfn string,
+	opts ProductOptions) {
+	CheckError(opts.Silent.Check())
+	model, _ := readModel(modelfn, opts.Silent)
+	logtraces := readLog(logfn)
+	i, err := strconv.Atoi(traceidx)
+	CheckError(err)
+	if i < 0 || i >= len(logtraces) {
+		CheckError(fmt.Errorf("Trace index %d out of range, the log has %d"+
+			" traces", i, len(logtraces)))
+	}
+	al, err := ReadAlignment(alignfn)
+	CheckError(err)
+	labels := NewLabelMap(&model, opts.LabelFile, opts.Normalize)
+	cost, err := model.ValidateAlignment(logtraces[i], al, labels)
+	if err != nil {
+		fmt.Println("INVALID: " + err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("VALID: cost %d\n", cost)
+}
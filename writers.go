@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Writers for the Petri net in formats of other model checkers. All writers
+// use the transition Name as action label, which for the synchronous product
+// is the move type (see PostProcessProduct).
+
+const (
+	FMTPNML string = "pnml"
+	FMTLOLA string = "lola" // LoLA
+	FMTNET  string = "net"  // Tina textual format
+	FMTNDR  string = "ndr"  // Tina graphical format (nd)
+	FMTTPN  string = "tpn"  // Woflan TPN
+)
+
+var (
+	FORMATS []string = []string{FMTPNML, FMTLOLA, FMTNET, FMTNDR, FMTTPN}
+)
+
+func CheckFormat(format string) error {
+	for _, f := range FORMATS {
+		if f == format {
+			return nil
+		}
+	}
+	return errors.New("Unknown output format '" + format + "'")
+}
+
+type weightedArc struct {
+	Place  string
+	Weight int
+}
+
+// returns the weighted in and out places of the transition, parallel arcs are
+// counted as arc weights
+func (pn *PNML) weightedArcs(trans Transition) ([]weightedArc, []weightedArc) {
+	inW := make(map[string]int)
+	outW := make(map[string]int)
+	for _, arc := range pn.Net.Page.Arcs {
+		if arc.Target == trans.ID {
+			inW[arc.Source] += 1
+		}
+		if arc.Source == trans.ID {
+			outW[arc.Target] += 1
+		}
+	}
+	return sortedArcs(inW), sortedArcs(outW)
+}
+
+func sortedArcs(weights map[string]int) []weightedArc {
+	var ret []weightedArc
+	for place, w := range weights {
+		ret = append(ret, weightedArc{Place: place, Weight: w})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Place < ret[j].Place })
+	return ret
+}
+
+func initialTokens(place Place) int {
+	count, _ := strconv.Atoi(place.InitialMarking)
+	return count
+}
+
+func (pn *PNML) ToPNML() string {
+	output, err := xml.Marshal(pn)
+	CheckError(err)
+	return string(output)
+}
+
+func (pn *PNML) ToLoLA() string {
+	ret := "{ " + pn.Net.Name + " }\n"
+	var places, marking []string
+	for _, place := range pn.Net.Page.Places {
+		places = append(places, place.ID)
+		if n := initialTokens(place); n > 0 {
+			marking = append(marking, fmt.Sprintf("%s: %d", place.ID, n))
+		}
+	}
+	ret += "PLACE " + strings.Join(places, ", ") + ";\n\n"
+	ret += "MARKING " + strings.Join(marking, ", ") + ";\n\n"
+	for _, trans := range pn.Net.Page.Transitions {
+		in, out := pn.weightedArcs(trans)
+		var consume, produce []string
+		for _, a := range in {
+			consume = append(consume, fmt.Sprintf("%s: %d", a.Place, a.Weight))
+		}
+		for _, a := range out {
+			produce = append(produce, fmt.Sprintf("%s: %d", a.Place, a.Weight))
+		}
+		ret += fmt.Sprintf("TRANSITION %s { %s }\n", trans.ID, trans.Name)
+		ret += "  CONSUME " + strings.Join(consume, ", ") + ";\n"
+		ret += "  PRODUCE " + strings.Join(produce, ", ") + ";\n\n"
+	}
+	return ret
+}
+
+// Tina place/transition names and labels are enclosed in braces
+func tinaName(name string) string {
+	return "{" + strings.NewReplacer("{", "\\{", "}", "\\}").Replace(name) + "}"
+}
+
+func tinaArcs(arcs []weightedArc) string {
+	ret := ""
+	for _, a := range arcs {
+		if a.Weight == 1 {
+			ret += " " + a.Place
+		} else {
+			ret += fmt.Sprintf(" %s*%d", a.Place, a.Weight)
+		}
+	}
+	return ret
+}
+
+func (pn *PNML) ToTinaNet() string {
+	ret := "net " + tinaName(pn.Net.Name) + "\n"
+	for _, trans := range pn.Net.Page.Transitions {
+		in, out := pn.weightedArcs(trans)
+		ret += fmt.Sprintf("tr %s : %s%s ->%s\n", trans.ID,
+			tinaName(trans.Name), tinaArcs(in), tinaArcs(out))
+	}
+	for _, place := range pn.Net.Page.Places {
+		ret += fmt.Sprintf("pl %s (%d)\n", place.ID, initialTokens(place))
+	}
+	return ret
+}
+
+// The nd format requires coordinates, places and transitions are put on a
+// simple grid with places in the top rows
+func (pn *PNML) ToTinaNdr() string {
+	const columns = 10
+	const spacing = 80
+	ret := ""
+	for i, place := range pn.Net.Page.Places {
+		ret += fmt.Sprintf("p %d.0 %d.0 %s %d n\n", (i%columns+1)*spacing,
+			(i/columns+1)*spacing, place.ID, initialTokens(place))
+	}
+	offset := (len(pn.Net.Page.Places)/columns + 2) * spacing
+	for i, trans := range pn.Net.Page.Transitions {
+		ret += fmt.Sprintf("t %d.0 %d.0 %s 0 w n %s n\n",
+			(i%columns+1)*spacing, offset+(i/columns)*spacing, trans.ID,
+			tinaName(trans.Name))
+	}
+	for _, trans := range pn.Net.Page.Transitions {
+		in, out := pn.weightedArcs(trans)
+		for _, a := range in {
+			ret += fmt.Sprintf("e %s %s %d n\n", a.Place, trans.ID, a.Weight)
+		}
+		for _, a := range out {
+			ret += fmt.Sprintf("e %s %s %d n\n", trans.ID, a.Place, a.Weight)
+		}
+	}
+	ret += "h " + tinaName(pn.Net.Name) + "\n"
+	return ret
+}
+
+func tpnArcs(arcs []weightedArc) string {
+	ret := ""
+	for _, a := range arcs {
+		for i := 0; i < a.Weight; i++ {
+			ret += fmt.Sprintf(" \"%s\"", a.Place)
+		}
+	}
+	return ret
+}
+
+func (pn *PNML) ToTPN() string {
+	ret := ""
+	for _, place := range pn.Net.Page.Places {
+		if n := initialTokens(place); n > 0 {
+			ret += fmt.Sprintf("place \"%s\" init %d;\n", place.ID, n)
+		} else {
+			ret += fmt.Sprintf("place \"%s\";\n", place.ID)
+		}
+	}
+	for _, trans := range pn.Net.Page.Transitions {
+		in, out := pn.weightedArcs(trans)
+		ret += fmt.Sprintf("trans \"%s\"~\"%s\" in%s out%s;\n", trans.ID,
+			trans.Name, tpnArcs(in), tpnArcs(out))
+	}
+	return ret
+}
+
+// writes the net to filename in the given format
+func (pn *PNML) WriteNet(format, filename string) {
+	switch format {
+	case FMTPNML:
+		WriteFile(filename, pn.ToPNML())
+	case FMTLOLA:
+		WriteFile(filename, pn.ToLoLA())
+	case FMTNET:
+		WriteFile(filename, pn.ToTinaNet())
+	case FMTNDR:
+		WriteFile(filename, pn.ToTinaNdr())
+	case FMTTPN:
+		WriteFile(filename, pn.ToTPN())
+	default:
+		CheckError(CheckFormat(format))
+	}
+}
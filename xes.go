@@ -13,9 +13,16 @@ type XES struct {
 }
 
 type XTrace struct {
-	XMLName xml.Name  `xml:"trace"`
-	Key     XKeyValue `xml:"string"`
-	Events  []XEvent  `xml:"event"`
+	XMLName    xml.Name     `xml:"trace"`
+	Attributes []XAttribute `xml:",any"`
+	Events     []XEvent     `xml:"event"`
+}
+
+// attribute of any type: string, date, int, float, boolean or id
+type XAttribute struct {
+	XMLName xml.Name
+	Key     string `xml:"key,attr"`
+	Value   string `xml:"value,attr"`
 }
 
 type XKeyValue struct {
@@ -25,8 +32,9 @@ type XKeyValue struct {
 }
 
 type XEvent struct {
-	XMLName   xml.Name    `xml:"event"`
-	EventKeys []XKeyValue `xml:"string"`
+	XMLName   xml.Name     `xml:"event"`
+	EventKeys []XKeyValue  `xml:"string"`
+	Dates     []XAttribute `xml:"date"`
 }
 
 func readXES(logfn string) []byte {
@@ -42,20 +50,50 @@ func readXES(logfn string) []byte {
 }
 
 func ParseXES(logfn string) [][]string {
+	var ret [][]string
+	for _, t := range ParseXESTraces(logfn) {
+		ret = append(ret, t.Events)
+	}
+	return ret
+}
+
+// returns the traces with their concept:name as ID, their attributes and
+// the time of their first and last event, see logfilter.go
+func ParseXESTraces(logfn string) []LogTrace {
 	contents := readPNML(logfn)
 	var xes XES
 	xml.Unmarshal(contents, &xes) // fill in XES contents
 
-	var ret [][]string
-	for _, t := range xes.XTraces {
-		var trace []string
+	var ret []LogTrace
+	for i, t := range xes.XTraces {
+		trace := LogTrace{ID: fmt.Sprint(i), Index: i,
+			Attributes: make(map[string]string)}
+		for _, attr := range t.Attributes {
+			trace.Attributes[attr.Key] = attr.Value
+		}
+		if name, ok := trace.Attributes["concept:name"]; ok {
+			trace.ID = name
+		}
 		for _, e := range t.Events {
 			for _, kv := range e.EventKeys {
 				if kv.Key == "concept:name" {
-					trace = append(trace, kv.Value)
+					trace.Events = append(trace.Events, kv.Value)
 					break
 				}
 			}
+			for _, date := range e.Dates {
+				if date.Key != "time:timestamp" {
+					continue
+				}
+				if ts, err := ParseFilterTime(date.Value); err == nil {
+					if trace.Start.IsZero() || ts.Before(trace.Start) {
+						trace.Start = ts
+					}
+					if ts.After(trace.End) {
+						trace.End = ts
+					}
+				}
+			}
 		}
 		ret = append(ret, trace)
 	}